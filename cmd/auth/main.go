@@ -25,6 +25,18 @@ func main() {
 		log.Fatal(err)
 	}
 
+	if args := flag.Args(); len(args) > 0 {
+		if args[0] != "migrate" {
+			log.Fatalf("unknown command %q", args[0])
+		}
+
+		if err := runMigrate(config.DatabaseURL, args[1:]); err != nil {
+			log.Fatal(err)
+		}
+
+		return
+	}
+
 	if err := server.Start(config); err != nil {
 		log.Fatal(err)
 	}
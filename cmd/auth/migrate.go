@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/schema"
+)
+
+const migrateUsage = "usage: auth migrate up | down [N] | status | version"
+
+// runMigrate handles `auth migrate <command>` against databaseURL.
+func runMigrate(databaseURL string, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	m, err := schema.New(databaseURL)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	switch args[0] {
+	case "up":
+		return m.Up()
+	case "down":
+		n := 1
+		if len(args) > 1 {
+			n, err = strconv.Atoi(args[1])
+			if err != nil {
+				return fmt.Errorf("bad number of migrations %q", args[1])
+			}
+		}
+
+		return m.Down(n)
+	case "status":
+		list, err := m.Status()
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range list {
+			state := "pending"
+			if migration.Applied {
+				state = "applied"
+			}
+			fmt.Printf("%d %-8s %s\n", migration.Version, state, migration.Name)
+		}
+
+		return nil
+	case "version":
+		version, dirty, err := m.Version()
+		if err != nil {
+			return err
+		}
+
+		if dirty {
+			fmt.Printf("%d (dirty)\n", version)
+		} else {
+			fmt.Println(version)
+		}
+
+		return nil
+	default:
+		return errors.New(migrateUsage)
+	}
+}
@@ -0,0 +1,49 @@
+// Package apperr is the catalogue of error kinds shared by storage and the
+// service layer. Transports map kinds to their own codes, see
+// server.statusCode; errors of no kind are internal and their details are
+// not shown to clients.
+package apperr
+
+import "errors"
+
+// Kinds. Test for them with errors.Is.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrValidation   = errors.New("validation failed")
+	ErrInvalidInput = errors.New("invalid input")
+)
+
+// Error is an error of some kind, with a message fit for clients and a
+// stable snake_case code they can branch on.
+type Error struct {
+	Kind    error
+	Code    string
+	Message string
+	Err     error // the cause, if any
+}
+
+// New returns an error of kind with code and message.
+func New(kind error, code string, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+// Wrap gives err a kind and code. The message of err is shown to clients,
+// so err must not carry internal details.
+func Wrap(kind error, code string, err error) *Error {
+	return &Error{Kind: kind, Code: code, Message: err.Error(), Err: err}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+
+	return []error{e.Kind, e.Err}
+}
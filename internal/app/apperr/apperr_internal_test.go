@@ -0,0 +1,25 @@
+package apperr
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestError(t *testing.T) {
+	errGone := New(ErrNotFound, "gone", "gone")
+	wrapped := fmt.Errorf("lookup: %w", errGone)
+
+	assert.ErrorIs(t, wrapped, errGone)
+	assert.ErrorIs(t, wrapped, ErrNotFound)
+	assert.NotErrorIs(t, wrapped, ErrConflict)
+	assert.Equal(t, "gone", errGone.Error())
+
+	cause := errors.New("bad proof")
+	err := Wrap(ErrUnauthorized, "bad_proof", cause)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	assert.ErrorIs(t, err, cause)
+	assert.Equal(t, "bad proof", err.Error())
+}
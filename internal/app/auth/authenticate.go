@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/models"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/storage"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/token"
+)
+
+// AuthenticateAccessToken verifies an access token. A DPoP-bound token is only
+// accepted if isDPoP, together with a proof made with the bound key.
+// Denylisted tokens and tokens of disabled users are rejected.
+// A token that fails verification, or whose user is gone, is ErrNotAuthenticated;
+// other errors, such as those of the storage, are returned as they are.
+func (s *Service) AuthenticateAccessToken(ctx context.Context, accessToken string, isDPoP bool, proof Proof) (*models.User, *token.UserClaims, error) {
+	claims, err := token.VerifyAccessToken(s.tokenMaker, accessToken)
+	if err != nil {
+		return nil, nil, ErrNotAuthenticated
+	}
+
+	bound := claims.Confirmation != nil && claims.Confirmation.JKT != ""
+	if bound != isDPoP {
+		return nil, nil, ErrNotAuthenticated
+	}
+
+	if bound {
+		err := s.dpop.VerifyBound(proof.JWT, proof.Method, proof.URL, accessToken, claims.Confirmation.JKT)
+		if err != nil {
+			return nil, nil, ErrNotAuthenticated
+		}
+	}
+
+	denied, err := s.isDenied(ctx, claims)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if denied {
+		return nil, nil, ErrNotAuthenticated
+	}
+
+	u, err := s.storage.User().FindByID(ctx, claims.ID)
+	if err != nil {
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			return nil, nil, ErrNotAuthenticated
+		}
+
+		return nil, nil, err
+	}
+
+	if u.IsDisabled {
+		return nil, nil, ErrUserDisabled
+	}
+
+	return u, claims, nil
+}
+
+// isDenied reports whether an access token was denied itself or in its revoked session.
+func (s *Service) isDenied(ctx context.Context, claims *token.UserClaims) (bool, error) {
+	denied, err := s.denylist.Contains(ctx, claims.RegisteredClaims.ID)
+	if err != nil || denied || claims.SessionID == "" {
+		return denied, err
+	}
+
+	return s.denylist.Contains(ctx, sessionDenylistKey(claims.SessionID))
+}
+
+// AuthenticateAPIKey verifies a personal API key and returns its owner with
+// the scopes the key is still granted. Errors are as of AuthenticateAccessToken.
+func (s *Service) AuthenticateAPIKey(ctx context.Context, apiKey string) (*models.User, []string, error) {
+	lookup, secret, ok := token.ParseAPIKey(apiKey)
+	if !ok {
+		return nil, nil, ErrNotAuthenticated
+	}
+
+	k, err := s.storage.APIKey().FindByPrefix(ctx, lookup)
+	if err != nil {
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			return nil, nil, ErrNotAuthenticated
+		}
+
+		return nil, nil, err
+	}
+
+	if !token.VerifyAPIKeySecret(secret, k.SecretHash) || k.IsExpired() {
+		return nil, nil, ErrNotAuthenticated
+	}
+
+	u, err := s.storage.User().FindByID(ctx, k.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			return nil, nil, ErrNotAuthenticated
+		}
+
+		return nil, nil, err
+	}
+
+	if u.IsDisabled {
+		return nil, nil, ErrUserDisabled
+	}
+
+	if err := s.storage.APIKey().UpdateLastUsed(ctx, k.ID, time.Now()); err != nil {
+		s.logger.Error(err)
+	}
+
+	// the key keeps only the scopes its owner is still allowed
+	return u, token.IntersectScopes(k.Scopes, s.allowedScopes(u)), nil
+}
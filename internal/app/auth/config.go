@@ -0,0 +1,26 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/dpop"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/session"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/token"
+)
+
+const (
+	RefreshTokenFormatJWT    = "jwt"
+	RefreshTokenFormatOpaque = "opaque"
+)
+
+// Config is the part of the server configuration the service works by.
+type Config struct {
+	RefreshTokenKey    string // keys refresh token digests
+	RefreshTokenFormat string
+	DefaultScopes      []string
+	DenylistInStorage  bool          // denied tokens go to the storage's denylist rather than to memory
+	DenylistCacheTTL   time.Duration // 0 disables the denylist cache
+	Token              *token.Config
+	DPoP               *dpop.Config
+	Session            *session.Config
+}
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/storage"
+)
+
+const denylistCacheSize = 10000
+
+// denylistCache remembers recent denylist lookups for ttl so that the store is
+// not queried on every authenticated request. Tokens denied by this instance
+// are rejected at once; those denied elsewhere within ttl.
+type denylistCache struct {
+	next    storage.DenylistRepository
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]denylistCacheEntry
+}
+
+type denylistCacheEntry struct {
+	denied bool
+	until  time.Time
+}
+
+func newDenylistCache(next storage.DenylistRepository, ttl time.Duration) *denylistCache {
+	return &denylistCache{
+		next:    next,
+		ttl:     ttl,
+		entries: make(map[string]denylistCacheEntry),
+	}
+}
+
+func (c *denylistCache) Add(ctx context.Context, jti string, expiresAt time.Time) error {
+	if err := c.next.Add(ctx, jti, expiresAt); err != nil {
+		return err
+	}
+
+	c.put(jti, denylistCacheEntry{denied: true, until: expiresAt})
+
+	return nil
+}
+
+func (c *denylistCache) Contains(ctx context.Context, jti string) (bool, error) {
+	c.mu.Lock()
+	e, ok := c.entries[jti]
+	c.mu.Unlock()
+
+	if ok && time.Now().Before(e.until) {
+		return e.denied, nil
+	}
+
+	denied, err := c.next.Contains(ctx, jti)
+	if err != nil {
+		return false, err
+	}
+
+	c.put(jti, denylistCacheEntry{denied: denied, until: time.Now().Add(c.ttl)})
+
+	return denied, nil
+}
+
+func (c *denylistCache) DeleteExpired(ctx context.Context, now time.Time, limit int) (int64, error) {
+	return c.next.DeleteExpired(ctx, now, limit)
+}
+
+// denylistCacheVia writes through to another store, typically one in a
+// transaction, and keeps the entries in the shared cache.
+type denylistCacheVia struct {
+	*denylistCache
+	store storage.DenylistRepository
+}
+
+func (c *denylistCacheVia) Add(ctx context.Context, jti string, expiresAt time.Time) error {
+	if err := c.store.Add(ctx, jti, expiresAt); err != nil {
+		return err
+	}
+
+	c.put(jti, denylistCacheEntry{denied: true, until: expiresAt})
+
+	return nil
+}
+
+func (c *denylistCache) put(jti string, e denylistCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= denylistCacheSize {
+		now := time.Now()
+		for id, old := range c.entries {
+			if !now.Before(old.until) {
+				delete(c.entries, id)
+			}
+		}
+
+		// still full of live entries: start over rather than grow without bound
+		if len(c.entries) >= denylistCacheSize {
+			c.entries = make(map[string]denylistCacheEntry)
+		}
+	}
+
+	c.entries[jti] = e
+}
+
+// denylistFor returns the denylist to record revocations made through st in.
+// A denylist kept in the database is written through st, joining its
+// transaction; written through the pool it would wait for the transaction's
+// own write lock on SQLite.
+func (s *Service) denylistFor(st storage.Storage) storage.DenylistRepository {
+	if !s.denylistInDB {
+		return s.denylist
+	}
+
+	if c, ok := s.denylist.(*denylistCache); ok {
+		return &denylistCacheVia{denylistCache: c, store: st.Denylist()}
+	}
+
+	return st.Denylist()
+}
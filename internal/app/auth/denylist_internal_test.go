@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/storage/memstore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDenylistCache(t *testing.T) {
+	ctx := context.Background()
+	store := memstore.NewDenylistRepository()
+	c := newDenylistCache(store, time.Minute)
+
+	denied, err := c.Contains(ctx, "a")
+	assert.NoError(t, err)
+	assert.False(t, denied)
+
+	// denied elsewhere: the cached answer holds until the ttl runs out
+	assert.NoError(t, store.Add(ctx, "a", time.Now().Add(time.Hour)))
+	denied, _ = c.Contains(ctx, "a")
+	assert.False(t, denied)
+
+	// denied through the cache: seen at once
+	assert.NoError(t, c.Add(ctx, "b", time.Now().Add(time.Hour)))
+	denied, _ = c.Contains(ctx, "b")
+	assert.True(t, denied)
+
+	c.ttl = 0
+	denied, _ = c.Contains(ctx, "c")
+	assert.False(t, denied)
+	assert.NoError(t, store.Add(ctx, "c", time.Now().Add(time.Hour)))
+	denied, _ = c.Contains(ctx, "c")
+	assert.True(t, denied)
+}
@@ -0,0 +1,17 @@
+package auth
+
+import "github.com/andreyxaxa/rest_auth_svc/internal/app/apperr"
+
+var (
+	ErrInvalidCredentials  = apperr.New(apperr.ErrUnauthorized, "invalid_credentials", "incorrect email or password")
+	ErrInvalidRefreshToken = apperr.New(apperr.ErrUnauthorized, "invalid_refresh_token", "invalid refresh token")
+	ErrInvalidSession      = apperr.New(apperr.ErrUnauthorized, "invalid_session", "invalid session")
+	ErrSessionRevoked      = apperr.New(apperr.ErrUnauthorized, "session_revoked", "session revoked")
+	ErrSessionExpired      = apperr.New(apperr.ErrUnauthorized, "session_expired", "session expired")
+	ErrInvalidAudience     = apperr.New(apperr.ErrInvalidInput, "invalid_audience", "invalid audience")
+	ErrInvalidScope        = apperr.New(apperr.ErrInvalidInput, "invalid_scope", "invalid scope")
+	ErrNotAuthenticated    = apperr.New(apperr.ErrUnauthorized, "not_authenticated", "not authenticated")
+	ErrIncorrectPassword   = apperr.New(apperr.ErrForbidden, "incorrect_password", "incorrect password")
+	ErrUserDisabled        = apperr.New(apperr.ErrForbidden, "user_disabled", "user disabled")
+	ErrTooManySessions     = apperr.New(apperr.ErrConflict, "too_many_sessions", "too many active sessions")
+)
@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"fmt"
+	"net/smtp"
+)
+
+func (s *Service) sendEmailWarning(email string, ip string) error {
+	return s.sendMail(email, "Warning: Another IP\nNew IP - "+ip)
+}
+
+func (s *Service) sendSessionEvictedNotice(email string, n int) error {
+	return s.sendMail(email, fmt.Sprintf("Warning: you were signed out of %d older session(s) because of a new sign-in", n))
+}
+
+func sendMail(email string, msg string) error {
+	auth := smtp.PlainAuth("",
+		"workauthml@gmail.com",
+		"z0of123laopL3rv",
+		"smtp.gmail.com",
+	)
+
+	err := smtp.SendMail(
+		"smtp.gmail.com:587",
+		auth,
+		"workauthml@gmail.com",
+		[]string{email},
+		[]byte(msg),
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
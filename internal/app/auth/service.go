@@ -0,0 +1,803 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/apperr"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/dpop"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/models"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/session"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/storage"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/storage/memstore"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/token"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// Service signs users in and issues, refreshes and revokes the tokens of
+// their sessions. It knows nothing of the transport: callers pass in what the
+// client sent and get domain types and apperr errors back.
+type Service struct {
+	storage       storage.Storage
+	tokenMaker    token.Maker
+	refreshHasher *token.RefreshTokenHasher
+	refreshFormat string
+	tokenConfig   *token.Config
+	defaultScopes []string
+	dpop          *dpop.Verifier
+	dpopConfig    *dpop.Config
+	denylist      storage.DenylistRepository
+	denylistInDB  bool
+	sessionConfig *session.Config
+	logger        *logrus.Logger
+	sendMail      func(email string, msg string) error
+}
+
+func New(config *Config, store storage.Storage, tokenMaker token.Maker, logger *logrus.Logger) *Service {
+	s := &Service{
+		storage:       store,
+		tokenMaker:    tokenMaker,
+		refreshHasher: token.NewRefreshTokenHasher(config.RefreshTokenKey),
+		refreshFormat: config.RefreshTokenFormat,
+		tokenConfig:   config.Token,
+		defaultScopes: config.DefaultScopes,
+		dpop:          dpop.NewVerifier(config.DPoP.ProofMaxAge, dpop.NewMemoryReplayCache()),
+		dpopConfig:    config.DPoP,
+		denylistInDB:  config.DenylistInStorage,
+		sessionConfig: config.Session,
+		logger:        logger,
+		sendMail:      sendMail,
+	}
+
+	var denylist storage.DenylistRepository = memstore.NewDenylistRepository()
+	if config.DenylistInStorage {
+		denylist = store.Denylist()
+	}
+
+	s.denylist = denylist
+	if config.DenylistCacheTTL > 0 {
+		s.denylist = newDenylistCache(denylist, config.DenylistCacheTTL)
+	}
+
+	return s
+}
+
+// Denylist returns the denylist access tokens are denied in.
+func (s *Service) Denylist() storage.DenylistRepository {
+	return s.denylist
+}
+
+// Login checks the credentials of a user and starts a new session.
+func (s *Service) Login(ctx context.Context, p *LoginParams) (*Tokens, error) {
+	if p.Audience != "" && !s.tokenConfig.AllowsAudience(p.Audience) {
+		return nil, ErrInvalidAudience
+	}
+
+	u, err := s.storage.User().FindByEmail(ctx, p.Email)
+	if err != nil {
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			return nil, ErrInvalidCredentials
+		}
+
+		return nil, err
+	}
+
+	if !u.ComparePassword(p.Password) {
+		return nil, ErrInvalidCredentials
+	}
+
+	if u.IsDisabled {
+		return nil, ErrUserDisabled
+	}
+
+	if u.PasswordNeedsRehash() {
+		u.Password = p.Password
+		if err := s.storage.User().UpdatePassword(ctx, u); err != nil {
+			s.logger.Error(err)
+		}
+		u.Sanitize()
+	}
+
+	scopes, err := grantScopes(p.Scope, s.allowedScopes(u))
+	if err != nil {
+		return nil, err
+	}
+
+	jkt, err := s.dpopThumbprint(p.Proof)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &grant{
+		sessionID:       uuid.New().String(),
+		audience:        p.Audience,
+		scopes:          scopes,
+		jkt:             jkt,
+		rememberMe:      p.RememberMe,
+		authenticatedAt: time.Now(),
+	}
+
+	return s.issue(ctx, s.storage, u, p.IP, g)
+}
+
+// IssueForUser starts a new session for the user with the given ID. Nothing
+// proves the caller is that user, so only the configured default scopes are
+// granted, never the user's own grants such as admin.
+func (s *Service) IssueForUser(ctx context.Context, p *IssueParams) (*Tokens, error) {
+	if p.Audience != "" && !s.tokenConfig.AllowsAudience(p.Audience) {
+		return nil, ErrInvalidAudience
+	}
+
+	u, err := s.storage.User().FindByID(ctx, p.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			return nil, ErrInvalidCredentials
+		}
+
+		return nil, err
+	}
+
+	if u.IsDisabled {
+		return nil, ErrUserDisabled
+	}
+
+	scopes, err := grantScopes(p.Scope, token.IntersectScopes(s.defaultScopes, s.allowedScopes(u)))
+	if err != nil {
+		return nil, err
+	}
+
+	jkt, err := s.dpopThumbprint(p.Proof)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &grant{
+		sessionID:       uuid.New().String(),
+		audience:        p.Audience,
+		scopes:          scopes,
+		jkt:             jkt,
+		authenticatedAt: time.Now(),
+	}
+
+	return s.issue(ctx, s.storage, u, p.IP, g)
+}
+
+// Refresh swaps a session for a new one with a fresh token pair. The refresh
+// token of the old session cannot be used again, nor can the access tokens
+// issued in it. A legacy refresh hash needs
+// no upgrade here: the new session is stored with a current one.
+func (s *Service) Refresh(ctx context.Context, p *RefreshParams) (*Tokens, error) {
+	u, err := s.storage.User().FindByID(ctx, p.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			return nil, ErrInvalidCredentials
+		}
+
+		return nil, err
+	}
+
+	if u.IsDisabled {
+		return nil, ErrUserDisabled
+	}
+
+	session, err := s.storage.Token().GetSession(ctx, p.SessionID)
+	if err != nil {
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+
+		return nil, err
+	}
+
+	if session.UserID != u.ID || !s.refreshHasher.Verify(p.RefreshToken, session.RefreshTokenHash) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// a JWT carries its own expiry, which the digest does not
+	if !token.IsOpaqueRefreshToken(p.RefreshToken) {
+		refreshClaims, err := token.VerifyRefreshToken(s.tokenMaker, p.RefreshToken, s.tokenConfig.LegacyRefreshBefore)
+		if err != nil {
+			return nil, apperr.Wrap(apperr.ErrUnauthorized, "invalid_token", err)
+		}
+
+		if refreshClaims.RegisteredClaims.ID != session.ID || refreshClaims.ID != u.ID {
+			return nil, ErrInvalidRefreshToken
+		}
+	}
+
+	if session.IsRevoked {
+		return nil, ErrSessionRevoked
+	}
+
+	if time.Now().After(session.ExpiresAt) {
+		return nil, ErrSessionExpired
+	}
+
+	if err := s.checkSessionBinding(p.Proof, session); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkSessionPolicy(ctx, session); err != nil {
+		return nil, err
+	}
+
+	g := s.sessionGrant(u, session)
+	g.sessionID = uuid.New().String() // refreshing rotates into a new session
+	g.replaces = session.ID
+
+	// the old session is swapped for the new one atomically; of concurrent
+	// refreshes with the same token only the first to lock the session wins
+	var tokens *Tokens
+	err = s.storage.WithTx(ctx, func(tx storage.Storage) error {
+		// user before session, as everywhere else: signing in, changing the
+		// password or disabling the user lock the user and then its sessions
+		if _, err := tx.User().FindByIDForUpdate(ctx, u.ID); err != nil {
+			return err
+		}
+
+		locked, err := tx.Token().GetSessionForUpdate(ctx, session.ID)
+		if err != nil {
+			if errors.Is(err, storage.ErrRecordNotFound) {
+				return ErrInvalidRefreshToken
+			}
+
+			return err
+		}
+
+		if locked.IsRevoked || locked.RefreshTokenHash != session.RefreshTokenHash {
+			return ErrInvalidRefreshToken
+		}
+
+		if time.Now().After(locked.ExpiresAt) {
+			return ErrSessionExpired
+		}
+
+		tokens, err = s.issue(ctx, tx, u, p.IP, g)
+		if err != nil {
+			return err
+		}
+
+		// access tokens of the old session carry its sid, which no later
+		// revocation sees once the session is replaced
+		return s.denySessionTokens(ctx, tx, locked)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// Renew issues a new access token in the session of a refresh token. The
+// returned Tokens carry no refresh token.
+func (s *Service) Renew(ctx context.Context, p *RenewParams) (*Tokens, error) {
+	var (
+		session *models.Session
+		u       *models.User
+		ip      string
+	)
+
+	if token.IsOpaqueRefreshToken(p.RefreshToken) {
+		var err error
+		session, err = s.storage.Token().GetSessionByRefreshTokenHash(ctx, s.refreshHasher.Hash(p.RefreshToken))
+		if err != nil {
+			if errors.Is(err, storage.ErrRecordNotFound) {
+				return nil, ErrInvalidRefreshToken
+			}
+
+			return nil, err
+		}
+
+		u, err = s.storage.User().FindByID(ctx, session.UserID)
+		if err != nil {
+			if errors.Is(err, storage.ErrRecordNotFound) {
+				return nil, ErrInvalidRefreshToken
+			}
+
+			return nil, err
+		}
+
+		ip = p.IP
+	} else {
+		refreshClaims, err := token.VerifyRefreshToken(s.tokenMaker, p.RefreshToken, s.tokenConfig.LegacyRefreshBefore)
+		if err != nil {
+			return nil, apperr.Wrap(apperr.ErrUnauthorized, "invalid_token", err)
+		}
+
+		session, err = s.storage.Token().GetSession(ctx, refreshClaims.RegisteredClaims.ID)
+		if err != nil {
+			if errors.Is(err, storage.ErrRecordNotFound) {
+				return nil, ErrInvalidRefreshToken
+			}
+
+			return nil, err
+		}
+
+		if session.UserID != refreshClaims.ID {
+			return nil, ErrInvalidSession
+		}
+
+		u, err = s.storage.User().FindByID(ctx, session.UserID)
+		if err != nil {
+			if errors.Is(err, storage.ErrRecordNotFound) {
+				return nil, ErrInvalidRefreshToken
+			}
+
+			return nil, err
+		}
+
+		if refreshClaims.IP != p.IP {
+			err = s.sendEmailWarning(u.Email, p.IP) // email-warning
+			if err != nil {
+				s.logger.Debug(err)
+			}
+		}
+
+		ip = refreshClaims.IP
+	}
+
+	if !s.refreshHasher.Verify(p.RefreshToken, session.RefreshTokenHash) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if session.IsRevoked {
+		return nil, ErrSessionRevoked
+	}
+
+	if u.IsDisabled {
+		return nil, ErrUserDisabled
+	}
+
+	if time.Now().After(session.ExpiresAt) {
+		return nil, ErrSessionExpired
+	}
+
+	if err := s.checkSessionBinding(p.Proof, session); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkSessionPolicy(ctx, session); err != nil {
+		return nil, err
+	}
+
+	// only a session still in use is worth the write
+	if s.refreshHasher.IsLegacy(session.RefreshTokenHash) {
+		err := s.storage.Token().UpdateRefreshTokenHash(ctx, session.ID, s.refreshHasher.Hash(p.RefreshToken))
+		if err != nil {
+			s.logger.Error(err)
+		}
+	}
+
+	g := s.sessionGrant(u, session)
+
+	accessToken, accessClaims, err := s.newAccessToken(u.ID, u.Email, ip, g)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.storage.Token().RecordAccessToken(ctx, session.ID, accessClaims.RegisteredClaims.ID, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return &Tokens{
+		User:                 u,
+		Session:              session,
+		AccessToken:          accessToken,
+		AccessTokenExpiresAt: accessClaims.RegisteredClaims.ExpiresAt.Time,
+		TokenType:            g.tokenType(),
+		Scopes:               g.scopes,
+	}, nil
+}
+
+// Logout revokes the session an access token of u was issued in and denies the token itself.
+func (s *Service) Logout(ctx context.Context, u *models.User, claims *token.UserClaims) error {
+	if claims.SessionID != "" {
+		session, err := s.storage.Token().GetSession(ctx, claims.SessionID)
+		if err == nil && session.UserID == u.ID {
+			if err := s.revokeSession(ctx, s.storage, session); err != nil {
+				return err
+			}
+		}
+	}
+
+	return s.denyAccessToken(ctx, claims)
+}
+
+// ChangePassword sets a new password and signs u out everywhere. claims are
+// those of the access token the change was made with, nil for other credentials.
+func (s *Service) ChangePassword(ctx context.Context, u *models.User, currentPassword string, newPassword string, claims *token.UserClaims) error {
+	if !u.ComparePassword(currentPassword) {
+		return ErrIncorrectPassword
+	}
+
+	u.Password = newPassword
+	if err := u.Validate(); err != nil {
+		return err
+	}
+
+	err := s.storage.WithTx(ctx, func(tx storage.Storage) error {
+		if err := tx.User().UpdatePassword(ctx, u); err != nil {
+			return err
+		}
+
+		return s.revokeUserSessions(ctx, tx, u)
+	})
+	u.Sanitize()
+	if err != nil {
+		return err
+	}
+
+	if claims != nil {
+		return s.denyAccessToken(ctx, claims)
+	}
+
+	return nil
+}
+
+// DisableUser blocks a user from signing in and revokes their sessions.
+func (s *Service) DisableUser(ctx context.Context, userID string) error {
+	u, err := s.storage.User().FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	return s.storage.WithTx(ctx, func(tx storage.Storage) error {
+		if err := tx.User().SetDisabled(ctx, u.ID, true); err != nil {
+			return err
+		}
+
+		return s.revokeUserSessions(ctx, tx, u)
+	})
+}
+
+// ----- helpers
+
+// allowedScopes returns the scopes u may be granted: the configured defaults plus the user's own grants.
+func (s *Service) allowedScopes(u *models.User) []string {
+	allowed := slices.Clone(s.defaultScopes)
+	for _, scope := range u.Scopes {
+		if !slices.Contains(allowed, scope) {
+			allowed = append(allowed, scope)
+		}
+	}
+
+	return allowed
+}
+
+// grantScopes narrows the space-delimited requested scope to allowed.
+// Nothing requested grants everything allowed; a request none of which is allowed is an error.
+func grantScopes(requested string, allowed []string) ([]string, error) {
+	scopes := token.GrantScopes(token.ParseScope(requested), allowed)
+	if requested != "" && len(scopes) == 0 {
+		return nil, ErrInvalidScope
+	}
+
+	return scopes, nil
+}
+
+// grant is what a token pair is issued for.
+type grant struct {
+	sessionID       string
+	audience        string
+	scopes          []string
+	jkt             string // DPoP key thumbprint, empty for bearer tokens
+	rememberMe      bool
+	authenticatedAt time.Time
+	replaces        string // session rotated into the new one, if any
+}
+
+func (g *grant) tokenType() string {
+	if g.jkt != "" {
+		return "DPoP"
+	}
+
+	return "Bearer"
+}
+
+// sessionGrant returns the grant of an existing session: the scopes granted at
+// login minus any the user has lost since. Sessions stored before scopes were
+// recorded get the default scopes, never everything the user is allowed now.
+func (s *Service) sessionGrant(u *models.User, session *models.Session) *grant {
+	scopes := token.ParseScope(session.Scope)
+	if len(scopes) == 0 {
+		scopes = s.defaultScopes
+	}
+
+	return &grant{
+		sessionID:       session.ID,
+		audience:        session.Audience,
+		scopes:          token.IntersectScopes(scopes, s.allowedScopes(u)),
+		jkt:             session.DPoPJKT,
+		rememberMe:      session.RememberMe,
+		authenticatedAt: session.AuthenticatedAt,
+	}
+}
+
+// issue creates an access and a refresh token for g and stores the session they belong to through st.
+func (s *Service) issue(ctx context.Context, st storage.Storage, u *models.User, ip string, g *grant) (*Tokens, error) {
+	accessToken, accessClaims, err := s.newAccessToken(u.ID, u.Email, ip, g)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, session, err := s.newRefreshToken(ctx, st, u, ip, g, accessClaims.RegisteredClaims.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tokens{
+		User:                 u,
+		Session:              session,
+		AccessToken:          accessToken,
+		AccessTokenExpiresAt: accessClaims.RegisteredClaims.ExpiresAt.Time,
+		RefreshToken:         refreshToken,
+		TokenType:            g.tokenType(),
+		Scopes:               g.scopes,
+	}, nil
+}
+
+func (s *Service) newAccessToken(userID string, email string, ip string, g *grant) (string, *token.UserClaims, error) {
+	return s.tokenMaker.CreateToken(&token.Params{
+		Use:       token.UseAccess,
+		UserID:    userID,
+		Email:     email,
+		IP:        ip,
+		Duration:  s.tokenConfig.AccessTTLFor(g.audience),
+		Audience:  audienceClaim(g.audience),
+		Scopes:    g.scopes,
+		JKT:       g.jkt,
+		SessionID: g.sessionID,
+	})
+}
+
+// newRefreshToken issues a refresh token in the configured format and stores the session it belongs to,
+// remembering the access token issued alongside.
+func (s *Service) newRefreshToken(ctx context.Context, st storage.Storage, u *models.User, ip string, g *grant, accessTokenID string) (string, *models.Session, error) {
+	now := time.Now()
+
+	var (
+		refreshToken string
+		session      = &models.Session{
+			ID:              g.sessionID,
+			UserID:          u.ID,
+			AccessTokenID:   accessTokenID,
+			RememberMe:      g.rememberMe,
+			AuthenticatedAt: g.authenticatedAt,
+			LastUsedAt:      now,
+			Audience:        g.audience,
+			Scope:           token.FormatScope(g.scopes),
+			DPoPJKT:         g.jkt,
+			IsRevoked:       false,
+		}
+		ttl = s.sessionConfig.PolicyFor(g.rememberMe).RefreshTTLAt(g.authenticatedAt, now, s.tokenConfig.RefreshTTLFor(g.audience))
+	)
+
+	switch s.refreshFormat {
+	case RefreshTokenFormatOpaque:
+		t, err := token.NewOpaqueRefreshToken()
+		if err != nil {
+			return "", nil, err
+		}
+
+		refreshToken = t
+		session.ExpiresAt = now.Add(ttl)
+	default:
+		t, refreshClaims, err := s.tokenMaker.CreateToken(&token.Params{
+			ID:       g.sessionID,
+			Use:      token.UseRefresh,
+			UserID:   u.ID,
+			Email:    u.Email,
+			IP:       ip,
+			Duration: ttl,
+			Audience: audienceClaim(g.audience),
+			Scopes:   g.scopes,
+			JKT:      g.jkt,
+		})
+		if err != nil {
+			return "", nil, err
+		}
+
+		refreshToken = t
+		session.ExpiresAt = refreshClaims.RegisteredClaims.ExpiresAt.Time
+	}
+
+	session.RefreshTokenHash = s.refreshHasher.Hash(refreshToken)
+
+	var evicted int
+	err := st.WithTx(ctx, func(tx storage.Storage) error {
+		var err error
+		if evicted, err = s.enforceSessionLimit(ctx, tx, u, g.replaces); err != nil {
+			return err
+		}
+
+		if g.replaces == "" {
+			session, err = tx.Token().CreateSession(ctx, session)
+			return err
+		}
+
+		// a session kept apart from tx, as in Redis, is still never seen
+		// missing or doubled mid-rotation
+		session, err = tx.Token().RotateSession(ctx, g.replaces, session)
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			return ErrInvalidRefreshToken
+		}
+
+		return err
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	if evicted > 0 {
+		go func() {
+			if err := s.sendSessionEvictedNotice(u.Email, evicted); err != nil {
+				s.logger.Debug(err)
+			}
+		}()
+	}
+
+	return refreshToken, session, nil
+}
+
+// enforceSessionLimit makes room for one more session of u, or fails with
+// ErrTooManySessions, according to the configured limit policy. It returns
+// the number of sessions evicted. The session being replaced, if any, does not
+// count. st must be a transaction: the user stays
+// locked until it ends, so concurrent sign-ins of u are counted one by one.
+// That holds for sessions kept in Redis too, as the lock is in the database.
+func (s *Service) enforceSessionLimit(ctx context.Context, st storage.Storage, u *models.User, replaces string) (int, error) {
+	limit := s.sessionConfig.MaxPerUser
+	if limit <= 0 {
+		return 0, nil
+	}
+
+	if _, err := st.User().FindByIDForUpdate(ctx, u.ID); err != nil {
+		return 0, err
+	}
+
+	sessions, err := st.Token().FindSessionsByUserID(ctx, u.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	active := sessions[:0]
+	for _, session := range sessions {
+		if session.ID == replaces || session.IsRevoked || now.After(session.ExpiresAt) || s.sessionConfig.PolicyFor(session.RememberMe).Check(session, now) != nil {
+			continue
+		}
+
+		active = append(active, session)
+	}
+
+	if len(active) < limit {
+		return 0, nil
+	}
+
+	if s.sessionConfig.LimitPolicy != session.LimitEvictOldest {
+		return 0, ErrTooManySessions
+	}
+
+	// sessions come oldest first
+	evicted := active[:len(active)-limit+1]
+	for _, session := range evicted {
+		if err := s.revokeSession(ctx, st, session); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(evicted), nil
+}
+
+// denyAccessToken puts an access token on the denylist until it expires.
+func (s *Service) denyAccessToken(ctx context.Context, claims *token.UserClaims) error {
+	return s.denylist.Add(ctx, claims.RegisteredClaims.ID, claims.RegisteredClaims.ExpiresAt.Add(s.tokenConfig.Leeway))
+}
+
+// revokeSession revokes session and denies every access token issued in it.
+func (s *Service) revokeSession(ctx context.Context, st storage.Storage, session *models.Session) error {
+	if err := st.Token().RevokeSession(ctx, session.ID); err != nil {
+		return err
+	}
+
+	return s.denySessionTokens(ctx, st, session)
+}
+
+// denySessionTokens denies every access token issued in session by their sid,
+// as well as the latest one by its jti, until the last of them expires.
+// A denylist kept in memory is not part of st's transaction: a rolled back
+// revocation still leaves the tokens denied, which errs on the safe side.
+func (s *Service) denySessionTokens(ctx context.Context, st storage.Storage, session *models.Session) error {
+	// the tokens were issued no later than now, so they expire within one access TTL
+	expiresAt := time.Now().Add(s.tokenConfig.AccessTTLFor(session.Audience) + s.tokenConfig.Leeway)
+
+	denylist := s.denylistFor(st)
+	if err := denylist.Add(ctx, sessionDenylistKey(session.ID), expiresAt); err != nil {
+		return err
+	}
+
+	if session.AccessTokenID == "" {
+		return nil
+	}
+
+	return denylist.Add(ctx, session.AccessTokenID, expiresAt)
+}
+
+// sessionDenylistKey is the denylist entry denying every token of a session.
+func sessionDenylistKey(sessionID string) string {
+	return "sid:" + sessionID
+}
+
+// revokeUserSessions revokes every active session of u in one unit of work.
+func (s *Service) revokeUserSessions(ctx context.Context, st storage.Storage, u *models.User) error {
+	return st.WithTx(ctx, func(tx storage.Storage) error {
+		sessions, err := tx.Token().FindSessionsByUserID(ctx, u.ID)
+		if err != nil {
+			return err
+		}
+
+		for _, session := range sessions {
+			if session.IsRevoked || time.Now().After(session.ExpiresAt) {
+				continue
+			}
+
+			if err := s.revokeSession(ctx, tx, session); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// dpopThumbprint verifies the DPoP proof of a token request, if DPoP is enabled
+// and the client sent one, and returns the thumbprint to bind the new tokens to.
+func (s *Service) dpopThumbprint(proof Proof) (string, error) {
+	if !s.dpopConfig.Enabled || proof.JWT == "" {
+		return "", nil
+	}
+
+	jkt, err := s.dpop.Verify(proof.JWT, proof.Method, proof.URL, "")
+	if err != nil {
+		return "", apperr.Wrap(apperr.ErrInvalidInput, "invalid_dpop_proof", err)
+	}
+
+	return jkt, nil
+}
+
+// checkSessionPolicy revokes session if it has been idle or alive for longer than its policy allows.
+func (s *Service) checkSessionPolicy(ctx context.Context, session *models.Session) error {
+	err := s.sessionConfig.PolicyFor(session.RememberMe).Check(session, time.Now())
+	if err != nil {
+		if err := s.revokeSession(ctx, s.storage, session); err != nil {
+			s.logger.Error(err)
+		}
+	}
+
+	return err
+}
+
+// checkSessionBinding requires a DPoP proof made with the session's key if the session is bound to one.
+func (s *Service) checkSessionBinding(proof Proof, session *models.Session) error {
+	if session.DPoPJKT == "" {
+		return nil
+	}
+
+	if err := s.dpop.VerifyBound(proof.JWT, proof.Method, proof.URL, "", session.DPoPJKT); err != nil {
+		return apperr.Wrap(apperr.ErrUnauthorized, "invalid_dpop_proof", err)
+	}
+
+	return nil
+}
+
+// audienceClaim returns the aud claim for a requested audience; nil selects the maker's default.
+func audienceClaim(audience string) []string {
+	if audience == "" {
+		return nil
+	}
+
+	return []string{audience}
+}
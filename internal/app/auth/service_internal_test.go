@@ -0,0 +1,1119 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/apperr"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/dpop"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/models"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/schema"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/session"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/storage"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/storage/memstore"
+	sqlstorage "github.com/andreyxaxa/rest_auth_svc/internal/app/storage/postgre"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/storage/redisstore"
+	sqlitestorage "github.com/andreyxaxa/rest_auth_svc/internal/app/storage/sqlite"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/token"
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestService(t *testing.T, modify ...func(*Config)) *Service {
+	t.Helper()
+
+	return newTestServiceWith(t, memstore.New(), modify...)
+}
+
+func newTestServiceWith(t *testing.T, store storage.Storage, modify ...func(*Config)) *Service {
+	t.Helper()
+
+	config := &Config{
+		RefreshTokenKey:    "test-secret",
+		RefreshTokenFormat: RefreshTokenFormatJWT,
+		DefaultScopes:      []string{"profile"},
+		Token:              token.NewConfig(),
+		DPoP:               dpop.NewConfig(),
+		Session:            session.NewConfig(),
+	}
+	for _, m := range modify {
+		m(config)
+	}
+
+	tokenMaker, err := token.NewMaker(config.Token, "test-secret")
+	require.NoError(t, err)
+
+	return New(config, store, tokenMaker, logrus.New())
+}
+
+func TestService_Sessions(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+
+	u := &models.User{Email: "user@example.org", Password: "Correct-Horse-7"}
+	require.NoError(t, s.storage.User().Create(ctx, u))
+
+	_, err := s.Login(ctx, &LoginParams{Email: u.Email, Password: "Wrong-Horse-7"})
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	_, err = s.Login(ctx, &LoginParams{Email: u.Email, Password: "Correct-Horse-7", Scope: "admin"})
+	assert.ErrorIs(t, err, ErrInvalidScope)
+
+	client := Client{IP: "192.0.2.1"}
+	login, err := s.Login(ctx, &LoginParams{Client: client, Email: u.Email, Password: "Correct-Horse-7"})
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer", login.TokenType)
+	assert.Equal(t, []string{"profile"}, login.Scopes)
+
+	refreshed, err := s.Refresh(ctx, &RefreshParams{Client: client, UserID: u.ID, SessionID: login.Session.ID, RefreshToken: login.RefreshToken})
+	require.NoError(t, err)
+	assert.NotEqual(t, login.Session.ID, refreshed.Session.ID)
+
+	_, err = s.Refresh(ctx, &RefreshParams{Client: client, UserID: u.ID, SessionID: login.Session.ID, RefreshToken: login.RefreshToken})
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+
+	renewed, err := s.Renew(ctx, &RenewParams{Client: client, RefreshToken: refreshed.RefreshToken})
+	require.NoError(t, err)
+	assert.Empty(t, renewed.RefreshToken)
+	assert.Equal(t, refreshed.Session.ID, renewed.Session.ID)
+
+	_, claims, err := s.AuthenticateAccessToken(ctx, renewed.AccessToken, false, Proof{})
+	require.NoError(t, err)
+
+	require.NoError(t, s.Logout(ctx, u, claims))
+
+	_, _, err = s.AuthenticateAccessToken(ctx, renewed.AccessToken, false, Proof{})
+	assert.ErrorIs(t, err, ErrNotAuthenticated)
+
+	_, err = s.Renew(ctx, &RenewParams{Client: client, RefreshToken: refreshed.RefreshToken})
+	assert.ErrorIs(t, err, ErrSessionRevoked)
+}
+
+func TestService_RefreshLegacyHash(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+
+	u := &models.User{Email: "user@example.org", Password: "Correct-Horse-7"}
+	require.NoError(t, s.storage.User().Create(ctx, u))
+
+	login, err := s.Login(ctx, &LoginParams{Email: u.Email, Password: "Correct-Horse-7"})
+	require.NoError(t, err)
+
+	// stored as is, like the first version of the service did
+	require.NoError(t, s.storage.Token().UpdateRefreshTokenHash(ctx, login.Session.ID, login.RefreshToken))
+
+	refreshed, err := s.Refresh(ctx, &RefreshParams{UserID: u.ID, SessionID: login.Session.ID, RefreshToken: login.RefreshToken})
+	require.NoError(t, err)
+
+	session, err := s.storage.Token().GetSession(ctx, refreshed.Session.ID)
+	require.NoError(t, err)
+	assert.False(t, s.refreshHasher.IsLegacy(session.RefreshTokenHash))
+}
+
+func TestService_LegacyHashOfRevokedSession(t *testing.T) {
+	for _, op := range []string{"renew", "refresh"} {
+		t.Run(op, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestService(t)
+
+			u := &models.User{Email: "user@example.org", Password: "Correct-Horse-7"}
+			require.NoError(t, s.storage.User().Create(ctx, u))
+
+			login, err := s.Login(ctx, &LoginParams{Email: u.Email, Password: "Correct-Horse-7"})
+			require.NoError(t, err)
+
+			require.NoError(t, s.storage.Token().UpdateRefreshTokenHash(ctx, login.Session.ID, login.RefreshToken))
+			require.NoError(t, s.storage.Token().RevokeSession(ctx, login.Session.ID))
+
+			if op == "renew" {
+				_, err = s.Renew(ctx, &RenewParams{RefreshToken: login.RefreshToken})
+			} else {
+				_, err = s.Refresh(ctx, &RefreshParams{UserID: u.ID, SessionID: login.Session.ID, RefreshToken: login.RefreshToken})
+			}
+			assert.ErrorIs(t, err, ErrSessionRevoked)
+
+			// a rejected request writes nothing
+			session, err := s.storage.Token().GetSession(ctx, login.Session.ID)
+			require.NoError(t, err)
+			assert.Equal(t, login.RefreshToken, session.RefreshTokenHash)
+		})
+	}
+}
+
+func TestService_RefreshExpired(t *testing.T) {
+	for _, format := range []string{RefreshTokenFormatJWT, RefreshTokenFormatOpaque} {
+		t.Run(format, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestService(t, func(c *Config) {
+				c.RefreshTokenFormat = format
+				c.Token.Audiences = map[string]*token.AudienceConfig{"cli": {RefreshTTL: 10 * time.Millisecond}}
+			})
+
+			u := &models.User{Email: "user@example.org", Password: "Correct-Horse-7"}
+			require.NoError(t, s.storage.User().Create(ctx, u))
+
+			login, err := s.Login(ctx, &LoginParams{Email: u.Email, Password: "Correct-Horse-7", Audience: "cli"})
+			require.NoError(t, err)
+
+			time.Sleep(20 * time.Millisecond)
+
+			_, err = s.Refresh(ctx, &RefreshParams{UserID: u.ID, SessionID: login.Session.ID, RefreshToken: login.RefreshToken})
+			assert.ErrorIs(t, err, apperr.ErrUnauthorized)
+			if format == RefreshTokenFormatOpaque {
+				assert.ErrorIs(t, err, ErrSessionExpired)
+			}
+		})
+	}
+}
+
+func TestService_RenewUnscopedSession(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t, func(c *Config) { c.RefreshTokenFormat = RefreshTokenFormatOpaque })
+
+	u := &models.User{Email: "admin@example.org", Password: "Correct-Horse-7", Scopes: []string{"admin"}}
+	require.NoError(t, s.storage.User().Create(ctx, u))
+
+	// stored before scopes were recorded
+	refreshToken, err := token.NewOpaqueRefreshToken()
+	require.NoError(t, err)
+	_, err = s.storage.Token().CreateSession(ctx, &models.Session{
+		ID:               "legacy",
+		UserID:           u.ID,
+		RefreshTokenHash: s.refreshHasher.Hash(refreshToken),
+		AuthenticatedAt:  time.Now(),
+		LastUsedAt:       time.Now(),
+		ExpiresAt:        time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	renewed, err := s.Renew(ctx, &RenewParams{RefreshToken: refreshToken})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"profile"}, renewed.Scopes)
+}
+
+func TestService_RevokeSessionDeniesEveryToken(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+
+	u := &models.User{Email: "user@example.org", Password: "Correct-Horse-7"}
+	require.NoError(t, s.storage.User().Create(ctx, u))
+
+	login, err := s.Login(ctx, &LoginParams{Email: u.Email, Password: "Correct-Horse-7"})
+	require.NoError(t, err)
+
+	renewed, err := s.Renew(ctx, &RenewParams{RefreshToken: login.RefreshToken})
+	require.NoError(t, err)
+
+	_, claims, err := s.AuthenticateAccessToken(ctx, renewed.AccessToken, false, Proof{})
+	require.NoError(t, err)
+	require.NoError(t, s.Logout(ctx, u, claims))
+
+	// issued before the latest renew, so not the one the session remembers
+	_, _, err = s.AuthenticateAccessToken(ctx, login.AccessToken, false, Proof{})
+	assert.ErrorIs(t, err, ErrNotAuthenticated)
+}
+
+func TestService_RefreshDeniesOldAccessTokens(t *testing.T) {
+	testCases := []struct {
+		name   string
+		revoke func(s *Service, u *models.User, claims *token.UserClaims) error
+	}{
+		{
+			name: "refresh only",
+		},
+		{
+			name: "logout",
+			revoke: func(s *Service, u *models.User, claims *token.UserClaims) error {
+				return s.Logout(context.Background(), u, claims)
+			},
+		},
+		{
+			name: "password change",
+			revoke: func(s *Service, u *models.User, claims *token.UserClaims) error {
+				return s.ChangePassword(context.Background(), u, "Correct-Horse-7", "Battery-Staple-8", claims)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestService(t)
+
+			u := &models.User{Email: "user@example.org", Password: "Correct-Horse-7"}
+			require.NoError(t, s.storage.User().Create(ctx, u))
+
+			login, err := s.Login(ctx, &LoginParams{Email: u.Email, Password: "Correct-Horse-7"})
+			require.NoError(t, err)
+
+			refreshed, err := s.Refresh(ctx, &RefreshParams{UserID: u.ID, SessionID: login.Session.ID, RefreshToken: login.RefreshToken})
+			require.NoError(t, err)
+
+			stored, claims, err := s.AuthenticateAccessToken(ctx, refreshed.AccessToken, false, Proof{})
+			require.NoError(t, err)
+
+			if tc.revoke != nil {
+				require.NoError(t, tc.revoke(s, stored, claims))
+			}
+
+			// issued in the replaced session, which no revocation sees any more
+			_, _, err = s.AuthenticateAccessToken(ctx, login.AccessToken, false, Proof{})
+			assert.ErrorIs(t, err, ErrNotAuthenticated)
+
+			_, _, err = s.AuthenticateAccessToken(ctx, refreshed.AccessToken, false, Proof{})
+			if tc.revoke != nil {
+				assert.ErrorIs(t, err, ErrNotAuthenticated)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestService_SessionLimit(t *testing.T) {
+	testCases := []struct {
+		name        string
+		policy      string
+		wantErr     error
+		wantActive  []int // of the three logins, by index
+		wantEvicted []int
+	}{
+		{
+			name:       "reject",
+			policy:     session.LimitReject,
+			wantErr:    ErrTooManySessions,
+			wantActive: []int{0, 1},
+		},
+		{
+			name:        "evict oldest",
+			policy:      session.LimitEvictOldest,
+			wantActive:  []int{1, 2},
+			wantEvicted: []int{0},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestService(t, func(c *Config) {
+				c.Session.MaxPerUser = 2
+				c.Session.LimitPolicy = tc.policy
+			})
+
+			mail := make(chan string, 1)
+			s.sendMail = func(email string, msg string) error {
+				mail <- msg
+				return nil
+			}
+
+			u := &models.User{Email: "user@example.org", Password: "Correct-Horse-7"}
+			require.NoError(t, s.storage.User().Create(ctx, u))
+
+			var logins []*Tokens
+			for i := 0; i < 3; i++ {
+				login, err := s.Login(ctx, &LoginParams{Email: u.Email, Password: "Correct-Horse-7"})
+				if i == 2 && tc.wantErr != nil {
+					assert.ErrorIs(t, err, tc.wantErr)
+					break
+				}
+				require.NoError(t, err)
+				logins = append(logins, login)
+			}
+
+			for _, i := range tc.wantActive {
+				_, _, err := s.AuthenticateAccessToken(ctx, logins[i].AccessToken, false, Proof{})
+				assert.NoError(t, err, "login %d", i)
+			}
+
+			for _, i := range tc.wantEvicted {
+				stored, err := s.storage.Token().GetSession(ctx, logins[i].Session.ID)
+				require.NoError(t, err)
+				assert.True(t, stored.IsRevoked, "login %d", i)
+
+				_, _, err = s.AuthenticateAccessToken(ctx, logins[i].AccessToken, false, Proof{})
+				assert.ErrorIs(t, err, ErrNotAuthenticated, "login %d", i)
+			}
+
+			if len(tc.wantEvicted) > 0 {
+				select {
+				case msg := <-mail:
+					assert.Contains(t, msg, "signed out of 1 older session(s)")
+				case <-time.After(time.Second):
+					t.Fatal("no eviction notice sent")
+				}
+			} else {
+				assert.Empty(t, mail)
+			}
+		})
+	}
+}
+
+func TestService_SessionLimitConcurrent(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t, func(c *Config) { c.Session.MaxPerUser = 3 })
+
+	u := &models.User{Email: "user@example.org", Password: "Correct-Horse-7"}
+	require.NoError(t, s.storage.User().Create(ctx, u))
+
+	var (
+		wg sync.WaitGroup
+		ok atomic.Int32
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if _, err := s.Login(ctx, &LoginParams{Email: u.Email, Password: "Correct-Horse-7"}); err == nil {
+				ok.Add(1)
+			} else {
+				assert.ErrorIs(t, err, ErrTooManySessions)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.EqualValues(t, 3, ok.Load())
+}
+
+func TestService_RefreshAtSessionLimit(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t, func(c *Config) {
+		c.Session.MaxPerUser = 1
+		c.Session.LimitPolicy = session.LimitReject
+	})
+
+	u := &models.User{Email: "user@example.org", Password: "Correct-Horse-7"}
+	require.NoError(t, s.storage.User().Create(ctx, u))
+
+	login, err := s.Login(ctx, &LoginParams{Email: u.Email, Password: "Correct-Horse-7"})
+	require.NoError(t, err)
+
+	// the session being replaced leaves room for its successor
+	refreshed, err := s.Refresh(ctx, &RefreshParams{UserID: u.ID, SessionID: login.Session.ID, RefreshToken: login.RefreshToken})
+	require.NoError(t, err)
+
+	sessions, err := s.storage.Token().FindSessionsByUserID(ctx, u.ID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, refreshed.Session.ID, sessions[0].ID)
+}
+
+func TestService_RefreshRetryAfterLimit(t *testing.T) {
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	store := redisstore.Wrap(memstore.New(), redisstore.NewTokenRepository(client))
+	s := newTestServiceWith(t, store, func(c *Config) {
+		c.Session.MaxPerUser = 1
+		c.Session.LimitPolicy = session.LimitReject
+	})
+
+	u := &models.User{Email: "user@example.org", Password: "Correct-Horse-7"}
+	require.NoError(t, s.storage.User().Create(ctx, u))
+
+	login, err := s.Login(ctx, &LoginParams{Email: u.Email, Password: "Correct-Horse-7"})
+	require.NoError(t, err)
+
+	// a session from before the limit was lowered
+	now := time.Now()
+	other, err := s.storage.Token().CreateSession(ctx, &models.Session{
+		ID:               uuid.NewString(),
+		UserID:           u.ID,
+		RefreshTokenHash: "other",
+		AuthenticatedAt:  now,
+		LastUsedAt:       now,
+		ExpiresAt:        now.Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	params := &RefreshParams{UserID: u.ID, SessionID: login.Session.ID, RefreshToken: login.RefreshToken}
+	_, err = s.Refresh(ctx, params)
+	require.ErrorIs(t, err, ErrTooManySessions)
+
+	// the rejected refresh does not hold on to the session
+	require.NoError(t, s.storage.Token().DeleteSession(ctx, other.ID))
+	_, err = s.Refresh(ctx, params)
+	assert.NoError(t, err)
+}
+
+// brokenUsers is a storage whose users cannot be looked up by id.
+type brokenUsers struct {
+	storage.Storage
+}
+
+func (b *brokenUsers) User() storage.UserRepository {
+	return &brokenUserRepository{UserRepository: b.Storage.User()}
+}
+
+type brokenUserRepository struct {
+	storage.UserRepository
+}
+
+var errConnectionReset = errors.New("connection reset")
+
+func (r *brokenUserRepository) FindByID(ctx context.Context, id string) (*models.User, error) {
+	return nil, errConnectionReset
+}
+
+func TestService_IssueForUser(t *testing.T) {
+	testCases := []struct {
+		name       string
+		userID     string // the admin created for the test when empty
+		scope      string
+		disabled   bool
+		broken     bool
+		wantScopes []string
+		wantErr    error
+	}{
+		{
+			name:       "default scopes",
+			wantScopes: []string{"profile"},
+		},
+		{
+			name:       "default scope requested",
+			scope:      "profile",
+			wantScopes: []string{"profile"},
+		},
+		{
+			name:    "own grant requested",
+			scope:   "admin",
+			wantErr: ErrInvalidScope,
+		},
+		{
+			name:    "unknown user",
+			userID:  "unknown",
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name:     "disabled user",
+			disabled: true,
+			wantErr:  ErrUserDisabled,
+		},
+		{
+			name:    "storage failure",
+			broken:  true,
+			wantErr: errConnectionReset,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := memstore.New()
+
+			u := &models.User{Email: "admin@example.org", Password: "Correct-Horse-7", Scopes: []string{"admin"}}
+			require.NoError(t, store.User().Create(ctx, u))
+			if tc.disabled {
+				require.NoError(t, store.User().SetDisabled(ctx, u.ID, true))
+			}
+
+			var st storage.Storage = store
+			if tc.broken {
+				st = &brokenUsers{Storage: store}
+			}
+			s := newTestServiceWith(t, st)
+
+			userID := tc.userID
+			if userID == "" {
+				userID = u.ID
+			}
+
+			tokens, err := s.IssueForUser(ctx, &IssueParams{UserID: userID, Scope: tc.scope})
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantScopes, tokens.Scopes)
+		})
+	}
+}
+
+func TestService_LoginGrantsOwnScopes(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+
+	u := &models.User{Email: "admin@example.org", Password: "Correct-Horse-7", Scopes: []string{"admin"}}
+	require.NoError(t, s.storage.User().Create(ctx, u))
+
+	login, err := s.Login(ctx, &LoginParams{Email: u.Email, Password: "Correct-Horse-7"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"profile", "admin"}, login.Scopes)
+}
+
+func TestService_ChangePassword(t *testing.T) {
+	testCases := []struct {
+		name        string
+		current     string
+		new         string
+		wantErr     error
+		wantInvalid bool
+	}{
+		{
+			name:    "changed",
+			current: "Correct-Horse-7",
+			new:     "Battery-Staple-8",
+		},
+		{
+			name:    "incorrect current password",
+			current: "Wrong-Horse-7",
+			new:     "Battery-Staple-8",
+			wantErr: ErrIncorrectPassword,
+		},
+		{
+			name:        "invalid new password",
+			current:     "Correct-Horse-7",
+			new:         "short",
+			wantInvalid: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestService(t)
+
+			u := &models.User{Email: "user@example.org", Password: "Correct-Horse-7"}
+			require.NoError(t, s.storage.User().Create(ctx, u))
+
+			login, err := s.Login(ctx, &LoginParams{Email: u.Email, Password: "Correct-Horse-7"})
+			require.NoError(t, err)
+			_, claims, err := s.AuthenticateAccessToken(ctx, login.AccessToken, false, Proof{})
+			require.NoError(t, err)
+
+			stored, err := s.storage.User().FindByID(ctx, u.ID)
+			require.NoError(t, err)
+
+			err = s.ChangePassword(ctx, stored, tc.current, tc.new, claims)
+			changed := tc.wantErr == nil && !tc.wantInvalid
+			switch {
+			case tc.wantErr != nil:
+				assert.ErrorIs(t, err, tc.wantErr)
+			case tc.wantInvalid:
+				var errs validation.Errors
+				assert.ErrorAs(t, err, &errs)
+			default:
+				require.NoError(t, err)
+			}
+
+			_, err = s.Login(ctx, &LoginParams{Email: u.Email, Password: "Correct-Horse-7"})
+			assert.Equal(t, changed, errors.Is(err, ErrInvalidCredentials))
+
+			// signed out everywhere, the token the change was made with included
+			session, err := s.storage.Token().GetSession(ctx, login.Session.ID)
+			require.NoError(t, err)
+			assert.Equal(t, changed, session.IsRevoked)
+
+			_, _, err = s.AuthenticateAccessToken(ctx, login.AccessToken, false, Proof{})
+			assert.Equal(t, changed, errors.Is(err, ErrNotAuthenticated))
+		})
+	}
+}
+
+func TestService_DisableUser(t *testing.T) {
+	testCases := []struct {
+		name    string
+		userID  string // the user created for the test when empty
+		wantErr error
+	}{
+		{
+			name: "disabled",
+		},
+		{
+			name:    "unknown user",
+			userID:  "unknown",
+			wantErr: storage.ErrRecordNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestService(t)
+
+			u := &models.User{Email: "user@example.org", Password: "Correct-Horse-7"}
+			require.NoError(t, s.storage.User().Create(ctx, u))
+
+			login, err := s.Login(ctx, &LoginParams{Email: u.Email, Password: "Correct-Horse-7"})
+			require.NoError(t, err)
+
+			userID := tc.userID
+			if userID == "" {
+				userID = u.ID
+			}
+
+			err = s.DisableUser(ctx, userID)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+
+				_, _, err = s.AuthenticateAccessToken(ctx, login.AccessToken, false, Proof{})
+				assert.NoError(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+
+			_, err = s.Login(ctx, &LoginParams{Email: u.Email, Password: "Correct-Horse-7"})
+			assert.ErrorIs(t, err, ErrUserDisabled)
+
+			_, err = s.Renew(ctx, &RenewParams{RefreshToken: login.RefreshToken})
+			assert.ErrorIs(t, err, ErrSessionRevoked)
+
+			_, _, err = s.AuthenticateAccessToken(ctx, login.AccessToken, false, Proof{})
+			assert.ErrorIs(t, err, ErrNotAuthenticated)
+		})
+	}
+}
+
+func newDPoPProof(t *testing.T, key *ecdsa.PrivateKey, htm string, htu string, accessToken string) string {
+	t.Helper()
+
+	claims := jwt.MapClaims{
+		"htm": htm,
+		"htu": htu,
+		"jti": uuid.New().String(),
+		"iat": time.Now().Unix(),
+	}
+	if accessToken != "" {
+		sum := sha256.Sum256([]byte(accessToken))
+		claims["ath"] = base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+
+	tok := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	tok.Header["typ"] = "dpop+jwt"
+	tok.Header["jwk"] = map[string]interface{}{
+		"kty": "EC",
+		"crv": "P-256",
+		"x":   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+		"y":   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+	}
+
+	s, err := tok.SignedString(key)
+	require.NoError(t, err)
+
+	return s
+}
+
+func TestService_DPoPBinding(t *testing.T) {
+	const (
+		loginURL   = "https://auth.example.com/login"
+		refreshURL = "https://auth.example.com/tokens/refresh"
+		meURL      = "https://auth.example.com/me"
+	)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name    string
+		op      string // renew, refresh or authenticate
+		key     *ecdsa.PrivateKey
+		url     string // the proof was made for; the request is to refreshURL or meURL
+		bearer  bool   // the access token presented as a bearer token
+		wantErr error
+	}{
+		{name: "renew without proof", op: "renew", wantErr: dpop.ErrInvalidProof},
+		{name: "renew by another key", op: "renew", key: other, wantErr: dpop.ErrKeyMismatch},
+		{name: "renew for another url", op: "renew", key: key, url: meURL, wantErr: dpop.ErrInvalidProof},
+		{name: "renew by the bound key", op: "renew", key: key},
+		{name: "refresh without proof", op: "refresh", wantErr: apperr.ErrUnauthorized},
+		{name: "refresh by another key", op: "refresh", key: other, wantErr: dpop.ErrKeyMismatch},
+		{name: "refresh by the bound key", op: "refresh", key: key},
+		{name: "authenticate as bearer", op: "authenticate", key: key, bearer: true, wantErr: ErrNotAuthenticated},
+		{name: "authenticate without proof", op: "authenticate", wantErr: ErrNotAuthenticated},
+		{name: "authenticate by another key", op: "authenticate", key: other, wantErr: ErrNotAuthenticated},
+		{name: "authenticate by the bound key", op: "authenticate", key: key},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestService(t, func(c *Config) { c.DPoP.Enabled = true })
+
+			u := &models.User{Email: "user@example.org", Password: "Correct-Horse-7"}
+			require.NoError(t, s.storage.User().Create(ctx, u))
+
+			login, err := s.Login(ctx, &LoginParams{
+				Client:   Client{Proof: Proof{JWT: newDPoPProof(t, key, "POST", loginURL, ""), Method: "POST", URL: loginURL}},
+				Email:    u.Email,
+				Password: "Correct-Horse-7",
+			})
+			require.NoError(t, err)
+			require.Equal(t, "DPoP", login.TokenType)
+
+			var (
+				method      = "POST"
+				url         = refreshURL
+				accessToken string
+			)
+			if tc.op == "authenticate" {
+				method, url, accessToken = "GET", meURL, login.AccessToken
+			}
+
+			var proof Proof
+			if tc.key != nil {
+				htu := url
+				if tc.url != "" {
+					htu = tc.url
+				}
+				proof = Proof{JWT: newDPoPProof(t, tc.key, method, htu, accessToken), Method: method, URL: url}
+			}
+
+			var tokens *Tokens
+			switch tc.op {
+			case "renew":
+				tokens, err = s.Renew(ctx, &RenewParams{Client: Client{Proof: proof}, RefreshToken: login.RefreshToken})
+			case "refresh":
+				tokens, err = s.Refresh(ctx, &RefreshParams{Client: Client{Proof: proof}, UserID: u.ID, SessionID: login.Session.ID, RefreshToken: login.RefreshToken})
+			case "authenticate":
+				_, _, err = s.AuthenticateAccessToken(ctx, login.AccessToken, !tc.bearer, proof)
+			}
+
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			if tokens != nil {
+				assert.Equal(t, "DPoP", tokens.TokenType)
+			}
+		})
+	}
+}
+
+func TestService_SessionPolicyRevokes(t *testing.T) {
+	testCases := []struct {
+		name        string
+		rememberMe  bool
+		signedIn    time.Duration // ago
+		idle        time.Duration
+		wantErr     error
+		wantRevoked bool
+	}{
+		{
+			name:     "active",
+			signedIn: time.Hour,
+			idle:     time.Minute,
+		},
+		{
+			name:        "idle too long",
+			signedIn:    3 * time.Hour,
+			idle:        3 * time.Hour,
+			wantErr:     session.ErrIdleTimeout,
+			wantRevoked: true,
+		},
+		{
+			name:        "alive too long",
+			signedIn:    25 * time.Hour,
+			idle:        time.Minute,
+			wantErr:     session.ErrLifetimeExpired,
+			wantRevoked: true,
+		},
+		{
+			name:       "remembered and idle within its policy",
+			rememberMe: true,
+			signedIn:   3 * time.Hour,
+			idle:       3 * time.Hour,
+		},
+	}
+
+	for _, tc := range testCases {
+		for _, op := range []string{"renew", "refresh"} {
+			t.Run(tc.name+"/"+op, func(t *testing.T) {
+				ctx := context.Background()
+				s := newTestService(t, func(c *Config) { c.RefreshTokenFormat = RefreshTokenFormatOpaque })
+
+				u := &models.User{Email: "user@example.org", Password: "Correct-Horse-7"}
+				require.NoError(t, s.storage.User().Create(ctx, u))
+
+				refreshToken, err := token.NewOpaqueRefreshToken()
+				require.NoError(t, err)
+
+				now := time.Now()
+				stored, err := s.storage.Token().CreateSession(ctx, &models.Session{
+					ID:               uuid.New().String(),
+					UserID:           u.ID,
+					Scope:            "profile",
+					RefreshTokenHash: s.refreshHasher.Hash(refreshToken),
+					RememberMe:       tc.rememberMe,
+					AuthenticatedAt:  now.Add(-tc.signedIn),
+					LastUsedAt:       now.Add(-tc.idle),
+					ExpiresAt:        now.Add(time.Hour),
+				})
+				require.NoError(t, err)
+
+				if op == "renew" {
+					_, err = s.Renew(ctx, &RenewParams{RefreshToken: refreshToken})
+				} else {
+					_, err = s.Refresh(ctx, &RefreshParams{UserID: u.ID, SessionID: stored.ID, RefreshToken: refreshToken})
+				}
+
+				if tc.wantErr != nil {
+					assert.ErrorIs(t, err, tc.wantErr)
+				} else {
+					assert.NoError(t, err)
+				}
+
+				if !tc.wantRevoked {
+					return
+				}
+
+				found, err := s.storage.Token().GetSession(ctx, stored.ID)
+				require.NoError(t, err)
+				assert.True(t, found.IsRevoked)
+
+				denied, err := s.denylist.Contains(ctx, sessionDenylistKey(stored.ID))
+				require.NoError(t, err)
+				assert.True(t, denied)
+			})
+		}
+	}
+}
+
+func TestService_RefreshConcurrent(t *testing.T) {
+	testCases := []struct {
+		name     string
+		newStore func(t *testing.T) storage.Storage
+	}{
+		{
+			name:     "memory",
+			newStore: func(t *testing.T) storage.Storage { return memstore.New() },
+		},
+		{
+			name: "redis",
+			newStore: func(t *testing.T) storage.Storage {
+				client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+				t.Cleanup(func() { client.Close() })
+
+				return redisstore.Wrap(memstore.New(), redisstore.NewTokenRepository(client))
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestServiceWith(t, tc.newStore(t))
+
+			u := &models.User{Email: "user@example.org", Password: "Correct-Horse-7"}
+			require.NoError(t, s.storage.User().Create(ctx, u))
+
+			login, err := s.Login(ctx, &LoginParams{Email: u.Email, Password: "Correct-Horse-7"})
+			require.NoError(t, err)
+
+			var (
+				wg sync.WaitGroup
+				ok atomic.Int32
+			)
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					_, err := s.Refresh(ctx, &RefreshParams{UserID: u.ID, SessionID: login.Session.ID, RefreshToken: login.RefreshToken})
+					if err == nil {
+						ok.Add(1)
+					} else {
+						assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+					}
+				}()
+			}
+			wg.Wait()
+
+			// the token is good for exactly one new session
+			assert.EqualValues(t, 1, ok.Load())
+
+			sessions, err := s.storage.Token().FindSessionsByUserID(ctx, u.ID)
+			require.NoError(t, err)
+			assert.Len(t, sessions, 1)
+		})
+	}
+}
+
+func TestService_RenewErrors(t *testing.T) {
+	testCases := []struct {
+		name         string
+		format       string
+		broken       bool
+		refreshToken string // the one of the login when empty
+		wantErr      error
+	}{
+		{
+			name:         "unknown opaque token",
+			format:       RefreshTokenFormatOpaque,
+			refreshToken: token.OpaqueRefreshTokenPrefix + "unknown",
+			wantErr:      ErrInvalidRefreshToken,
+		},
+		{
+			name:    "storage failure with an opaque token",
+			format:  RefreshTokenFormatOpaque,
+			broken:  true,
+			wantErr: errConnectionReset,
+		},
+		{
+			name:    "storage failure with a jwt",
+			format:  RefreshTokenFormatJWT,
+			broken:  true,
+			wantErr: errConnectionReset,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := memstore.New()
+			format := func(c *Config) { c.RefreshTokenFormat = tc.format }
+			s := newTestServiceWith(t, store, format)
+
+			u := &models.User{Email: "user@example.org", Password: "Correct-Horse-7"}
+			require.NoError(t, store.User().Create(ctx, u))
+
+			login, err := s.Login(ctx, &LoginParams{Email: u.Email, Password: "Correct-Horse-7"})
+			require.NoError(t, err)
+
+			if tc.broken {
+				s = newTestServiceWith(t, &brokenUsers{Storage: store}, format)
+			}
+
+			refreshToken := tc.refreshToken
+			if refreshToken == "" {
+				refreshToken = login.RefreshToken
+			}
+
+			_, err = s.Renew(ctx, &RenewParams{RefreshToken: refreshToken})
+			assert.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+// sqlStores returns the SQL backends to run a test on: SQLite, and Postgres
+// when TEST_DATABASE_URL names a database the test may wipe.
+func sqlStores(t *testing.T) map[string]func(t *testing.T) storage.Storage {
+	t.Helper()
+
+	stores := map[string]func(t *testing.T) storage.Storage{
+		"sqlite": func(t *testing.T) storage.Storage {
+			path := filepath.Join(t.TempDir(), "auth.db")
+
+			m, err := schema.New("sqlite://" + path)
+			require.NoError(t, err)
+			require.NoError(t, m.Up())
+			require.NoError(t, m.Close())
+
+			db, err := sqlitestorage.Open(path)
+			require.NoError(t, err)
+			t.Cleanup(func() { db.Close() })
+
+			return sqlitestorage.New(db)
+		},
+	}
+
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		return stores
+	}
+
+	stores["postgres"] = func(t *testing.T) storage.Storage {
+		m, err := schema.New(databaseURL)
+		require.NoError(t, err)
+		require.NoError(t, m.Up())
+		require.NoError(t, m.Close())
+
+		db, err := sql.Open("postgres", databaseURL)
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+
+		_, err = db.Exec("TRUNCATE users, sessions, api_keys, token_denylist CASCADE")
+		require.NoError(t, err)
+
+		return sqlstorage.New(db)
+	}
+
+	return stores
+}
+
+// TestService_LoginEvictingWhileRefreshing runs sign-ins that evict the
+// oldest session alongside refreshes of those sessions. Both lock the user
+// before its sessions, so Postgres finds no deadlock between them.
+func TestService_LoginEvictingWhileRefreshing(t *testing.T) {
+	for name, newStore := range sqlStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestServiceWith(t, newStore(t), func(c *Config) {
+				c.Session.MaxPerUser = 2
+				c.Session.LimitPolicy = session.LimitEvictOldest
+			})
+			s.sendMail = func(email string, msg string) error { return nil }
+
+			u := &models.User{Email: "user@example.org", Password: "Correct-Horse-7"}
+			require.NoError(t, s.storage.User().Create(ctx, u))
+
+			// called from the goroutines, so it reports with Errorf
+			login := func() *Tokens {
+				tokens, err := s.Login(ctx, &LoginParams{Email: u.Email, Password: "Correct-Horse-7"})
+				if err != nil {
+					t.Errorf("login: %v", err)
+				}
+				return tokens
+			}
+
+			var wg sync.WaitGroup
+			for i := 0; i < 2; i++ {
+				wg.Add(2)
+
+				go func() {
+					defer wg.Done()
+
+					for j := 0; j < 5; j++ {
+						login()
+					}
+				}()
+
+				go func() {
+					defer wg.Done()
+
+					tokens := login()
+					for j := 0; j < 5 && tokens != nil; j++ {
+						refreshed, err := s.Refresh(ctx, &RefreshParams{UserID: u.ID, SessionID: tokens.Session.ID, RefreshToken: tokens.RefreshToken})
+						if err == nil {
+							tokens = refreshed
+							continue
+						}
+
+						// evicted in the meantime
+						if !errors.Is(err, ErrSessionRevoked) && !errors.Is(err, ErrInvalidRefreshToken) {
+							t.Errorf("refresh: %v", err)
+							return
+						}
+						tokens = login()
+					}
+				}()
+			}
+			wg.Wait()
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/models"
+)
+
+// Proof is a DPoP proof with the method and URL of the request it came with.
+// An empty JWT means the client sent none.
+type Proof struct {
+	JWT    string
+	Method string
+	URL    string
+}
+
+// Client is what is known of the client making a call.
+type Client struct {
+	IP    string
+	Proof Proof
+}
+
+type LoginParams struct {
+	Client
+	Email      string
+	Password   string
+	Audience   string
+	Scope      string // space-delimited, empty for everything allowed
+	RememberMe bool   // selects the longer session policy
+}
+
+type IssueParams struct {
+	Client
+	UserID   string
+	Audience string
+	Scope    string
+}
+
+type RefreshParams struct {
+	Client
+	UserID       string
+	SessionID    string
+	RefreshToken string
+}
+
+type RenewParams struct {
+	Client
+	RefreshToken string
+}
+
+// Tokens is what a session was issued: an access token, and a refresh token
+// unless only the access token was renewed.
+type Tokens struct {
+	User                 *models.User
+	Session              *models.Session
+	AccessToken          string
+	AccessTokenExpiresAt time.Time
+	RefreshToken         string
+	TokenType            string // Bearer or DPoP
+	Scopes               []string
+}
@@ -0,0 +1,19 @@
+package dpop
+
+import "time"
+
+type Config struct {
+	// Enabled makes token requests carrying a DPoP proof get key-bound tokens.
+	// Sessions bound earlier are checked regardless.
+	Enabled     bool          `toml:"enabled"`
+	ProofMaxAge time.Duration `toml:"proof_max_age"`
+	// BaseURL is the external scheme and host proofs are made for (htu), e.g.
+	// https://auth.example.com. Derived from the request when empty.
+	BaseURL string `toml:"base_url"`
+}
+
+func NewConfig() *Config {
+	return &Config{
+		ProofMaxAge: time.Minute,
+	}
+}
@@ -0,0 +1,136 @@
+package dpop
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// HeaderName is the request header carrying the proof.
+	HeaderName = "DPoP"
+
+	proofType = "dpop+jwt"
+)
+
+var (
+	ErrInvalidProof = errors.New("invalid dpop proof")
+	ErrReplayed     = errors.New("dpop proof replayed")
+	ErrKeyMismatch  = errors.New("dpop key does not match token binding")
+
+	validMethods = []string{"ES256", "ES384", "ES512", "RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "EdDSA"}
+)
+
+type proofClaims struct {
+	HTM string `json:"htm"`
+	HTU string `json:"htu"`
+	ATH string `json:"ath,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Verifier checks DPoP proof JWTs (RFC 9449).
+type Verifier struct {
+	maxAge time.Duration
+	replay ReplayCache
+}
+
+func NewVerifier(maxAge time.Duration, replay ReplayCache) *Verifier {
+	return &Verifier{
+		maxAge: maxAge,
+		replay: replay,
+	}
+}
+
+// Verify checks proof for a request with the given method and URL and returns
+// the JWK thumbprint of the key that signed it. If accessToken is not empty the
+// proof must carry its hash in ath.
+func (v *Verifier) Verify(proof string, method string, requestURL string, accessToken string) (string, error) {
+	var key *jwk
+
+	claims := &proofClaims{}
+	_, err := jwt.ParseWithClaims(proof, claims, func(t *jwt.Token) (interface{}, error) {
+		if typ, _ := t.Header["typ"].(string); !strings.EqualFold(typ, proofType) {
+			return nil, fmt.Errorf("%w: typ must be %s", ErrInvalidProof, proofType)
+		}
+
+		k, err := jwkFromHeader(t.Header["jwk"])
+		if err != nil {
+			return nil, err
+		}
+		key = k
+
+		return k.publicKey()
+	}, jwt.WithValidMethods(validMethods), jwt.WithoutClaimsValidation())
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+
+	if claims.ID == "" || claims.IssuedAt == nil {
+		return "", fmt.Errorf("%w: jti and iat are required", ErrInvalidProof)
+	}
+
+	if claims.HTM != method {
+		return "", fmt.Errorf("%w: htm mismatch", ErrInvalidProof)
+	}
+
+	if !sameURL(claims.HTU, requestURL) {
+		return "", fmt.Errorf("%w: htu mismatch", ErrInvalidProof)
+	}
+
+	iat := claims.IssuedAt.Time
+	if age := time.Since(iat); age > v.maxAge || age < -v.maxAge {
+		return "", fmt.Errorf("%w: iat outside of the allowed window", ErrInvalidProof)
+	}
+
+	if accessToken != "" {
+		sum := sha256.Sum256([]byte(accessToken))
+		ath := base64.RawURLEncoding.EncodeToString(sum[:])
+		if subtle.ConstantTimeCompare([]byte(claims.ATH), []byte(ath)) != 1 {
+			return "", fmt.Errorf("%w: ath mismatch", ErrInvalidProof)
+		}
+	}
+
+	if v.replay.Seen(key.thumbprint()+":"+claims.ID, iat.Add(v.maxAge)) {
+		return "", ErrReplayed
+	}
+
+	return key.thumbprint(), nil
+}
+
+// VerifyBound is Verify plus a check that the proof key is the one jkt was bound to.
+func (v *Verifier) VerifyBound(proof string, method string, requestURL string, accessToken string, jkt string) error {
+	got, err := v.Verify(proof, method, requestURL, accessToken)
+	if err != nil {
+		return err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(got), []byte(jkt)) != 1 {
+		return ErrKeyMismatch
+	}
+
+	return nil
+}
+
+// sameURL compares htu to the request URL ignoring query and fragment.
+func sameURL(htu string, requestURL string) bool {
+	a, err := url.Parse(htu)
+	if err != nil {
+		return false
+	}
+
+	b, err := url.Parse(requestURL)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(a.Scheme, b.Scheme) &&
+		strings.EqualFold(a.Host, b.Host) &&
+		a.EscapedPath() == b.EscapedPath()
+}
@@ -0,0 +1,76 @@
+package dpop
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func newProof(t *testing.T, key *ecdsa.PrivateKey, htm string, htu string, accessToken string) string {
+	t.Helper()
+
+	claims := &proofClaims{
+		HTM: htm,
+		HTU: htu,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:       uuid.New().String(),
+			IssuedAt: jwt.NewNumericDate(time.Now()),
+		},
+	}
+	if accessToken != "" {
+		sum := sha256.Sum256([]byte(accessToken))
+		claims.ATH = base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+
+	tok := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	tok.Header["typ"] = proofType
+	tok.Header["jwk"] = map[string]interface{}{
+		"kty": "EC",
+		"crv": "P-256",
+		"x":   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+		"y":   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+	}
+
+	s, err := tok.SignedString(key)
+	assert.NoError(t, err)
+
+	return s
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	v := NewVerifier(time.Minute, NewMemoryReplayCache())
+	url := "https://auth.example.com/tokens/renew"
+
+	proof := newProof(t, key, "POST", url+"?x=1", "")
+	jkt, err := v.Verify(proof, "POST", url, "")
+	assert.NoError(t, err)
+	assert.Len(t, jkt, 43)
+
+	_, err = v.Verify(proof, "POST", url, "")
+	assert.ErrorIs(t, err, ErrReplayed)
+
+	_, err = v.Verify(newProof(t, key, "GET", url, ""), "POST", url, "")
+	assert.ErrorIs(t, err, ErrInvalidProof)
+
+	_, err = v.Verify(newProof(t, key, "POST", "https://evil.example.com/tokens/renew", ""), "POST", url, "")
+	assert.ErrorIs(t, err, ErrInvalidProof)
+
+	_, err = v.Verify(newProof(t, key, "GET", url, "access"), "GET", url, "other-access")
+	assert.ErrorIs(t, err, ErrInvalidProof)
+
+	assert.NoError(t, v.VerifyBound(newProof(t, key, "GET", url, "access"), "GET", url, "access", jkt))
+	assert.ErrorIs(t, v.VerifyBound(newProof(t, other, "GET", url, "access"), "GET", url, "access", jkt), ErrKeyMismatch)
+}
@@ -0,0 +1,48 @@
+package dpop
+
+import (
+	"sync"
+	"time"
+)
+
+// ReplayCache remembers proof jti values until they can no longer pass the iat check.
+type ReplayCache interface {
+	// Seen records jti and reports whether it had already been recorded.
+	Seen(jti string, expiresAt time.Time) bool
+}
+
+type memoryReplayCache struct {
+	mu        sync.Mutex
+	entries   map[string]time.Time
+	lastSweep time.Time
+}
+
+// NewMemoryReplayCache returns a process-local ReplayCache. With several
+// replicas a proof can be replayed once against each of them.
+func NewMemoryReplayCache() ReplayCache {
+	return &memoryReplayCache{
+		entries: make(map[string]time.Time),
+	}
+}
+
+func (c *memoryReplayCache) Seen(jti string, expiresAt time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.lastSweep) > time.Minute {
+		for k, exp := range c.entries {
+			if now.After(exp) {
+				delete(c.entries, k)
+			}
+		}
+		c.lastSweep = now
+	}
+
+	if exp, ok := c.entries[jti]; ok && now.Before(exp) {
+		return true
+	}
+
+	c.entries[jti] = expiresAt
+	return false
+}
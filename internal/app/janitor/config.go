@@ -0,0 +1,21 @@
+package janitor
+
+import "time"
+
+type Config struct {
+	Enabled   bool          `toml:"enabled"`
+	Interval  time.Duration `toml:"interval"`
+	BatchSize int           `toml:"batch_size"`
+	// RevokedRetention keeps revoked sessions around for this long before
+	// they are purged, so that recent revocations can still be looked into.
+	RevokedRetention time.Duration `toml:"revoked_retention"`
+}
+
+func NewConfig() *Config {
+	return &Config{
+		Enabled:          true,
+		Interval:         5 * time.Minute,
+		BatchSize:        1000,
+		RevokedRetention: 24 * time.Hour,
+	}
+}
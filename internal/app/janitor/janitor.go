@@ -0,0 +1,127 @@
+package janitor
+
+import (
+	"context"
+	"expvar"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// lockKey is the advisory lock that keeps the janitor to one replica at a time.
+const lockKey int64 = 0x6a616e69746f72 // "janitor"
+
+// metrics is published as "janitor" in /debug/vars.
+var metrics = expvar.NewMap("janitor")
+
+// Locker takes a lock shared by all replicas. TryLock does not wait: ok is
+// false if another replica holds the lock.
+type Locker interface {
+	TryLock(ctx context.Context, key int64) (unlock func(), ok bool, err error)
+}
+
+// SessionPurger deletes up to limit sessions that expired before now or were
+// revoked before revokedBefore.
+type SessionPurger interface {
+	DeleteExpiredSessions(ctx context.Context, now time.Time, revokedBefore time.Time, limit int) (int64, error)
+}
+
+// DenylistPurger deletes up to limit denylist entries that expired before now.
+type DenylistPurger interface {
+	DeleteExpired(ctx context.Context, now time.Time, limit int) (int64, error)
+}
+
+// Janitor periodically purges rows nobody will read again.
+type Janitor struct {
+	config   *Config
+	locker   Locker
+	sessions SessionPurger
+	denylist DenylistPurger
+	logger   *logrus.Logger
+}
+
+func New(config *Config, locker Locker, sessions SessionPurger, denylist DenylistPurger, logger *logrus.Logger) *Janitor {
+	return &Janitor{
+		config:   config,
+		locker:   locker,
+		sessions: sessions,
+		denylist: denylist,
+		logger:   logger,
+	}
+}
+
+// Run calls RunOnce every interval until ctx is done. A run that takes
+// longer than the interval is cancelled.
+func (j *Janitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		runCtx, cancel := context.WithTimeout(ctx, j.config.Interval)
+		err := j.RunOnce(runCtx)
+		cancel()
+		if err != nil && ctx.Err() == nil {
+			metrics.Add("errors", 1)
+			j.logger.Error(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce purges everything due, batch by batch, unless another replica is already at it.
+func (j *Janitor) RunOnce(ctx context.Context) error {
+	unlock, ok, err := j.locker.TryLock(ctx, lockKey)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		metrics.Add("skipped", 1)
+		return nil
+	}
+	defer unlock()
+
+	metrics.Add("runs", 1)
+
+	now := time.Now()
+
+	n, err := j.purge(func() (int64, error) {
+		return j.sessions.DeleteExpiredSessions(ctx, now, now.Add(-j.config.RevokedRetention), j.config.BatchSize)
+	})
+	metrics.Add("sessions_removed", n)
+	if err != nil {
+		return err
+	}
+
+	n, err = j.purge(func() (int64, error) {
+		return j.denylist.DeleteExpired(ctx, now, j.config.BatchSize)
+	})
+	metrics.Add("denylist_removed", n)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// purge repeats deleteBatch until a batch comes back short or empty and returns the rows removed.
+func (j *Janitor) purge(deleteBatch func() (int64, error)) (int64, error) {
+	var total int64
+
+	for {
+		n, err := deleteBatch()
+		total += n
+		if err != nil {
+			return total, err
+		}
+
+		if n == 0 || n < int64(j.config.BatchSize) {
+			return total, nil
+		}
+	}
+}
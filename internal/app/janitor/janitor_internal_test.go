@@ -0,0 +1,106 @@
+package janitor
+
+import (
+	"context"
+	"expvar"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeLocker struct {
+	held bool
+}
+
+func (l *fakeLocker) TryLock(ctx context.Context, key int64) (func(), bool, error) {
+	if l.held {
+		return nil, false, nil
+	}
+
+	l.held = true
+	return func() { l.held = false }, true, nil
+}
+
+// fakePurger removes rows batch by batch and records each limit it was called with.
+type fakePurger struct {
+	rows   int64
+	limits []int
+}
+
+func (p *fakePurger) purge(limit int) int64 {
+	p.limits = append(p.limits, limit)
+
+	n := min(p.rows, int64(limit))
+	p.rows -= n
+	return n
+}
+
+func (p *fakePurger) DeleteExpiredSessions(ctx context.Context, now time.Time, revokedBefore time.Time, limit int) (int64, error) {
+	return p.purge(limit), nil
+}
+
+func (p *fakePurger) DeleteExpired(ctx context.Context, now time.Time, limit int) (int64, error) {
+	return p.purge(limit), nil
+}
+
+func TestJanitor_RunOnce(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	config := NewConfig()
+	config.BatchSize = 10
+
+	sessions := &fakePurger{rows: 25}
+	denylist := &fakePurger{rows: 10}
+	locker := &fakeLocker{}
+
+	before := metrics.Get("sessions_removed")
+
+	j := New(config, locker, sessions, denylist, logger)
+	assert.NoError(t, j.RunOnce(context.Background()))
+
+	assert.Zero(t, sessions.rows)
+	assert.Len(t, sessions.limits, 3)
+	assert.Zero(t, denylist.rows)
+	assert.Len(t, denylist.limits, 2) // a full batch is followed by an empty one
+	assert.False(t, locker.held)
+
+	removed := metrics.Get("sessions_removed").(*expvar.Int).Value()
+	if before != nil {
+		removed -= before.(*expvar.Int).Value()
+	}
+	assert.Equal(t, int64(25), removed)
+
+	// another replica holds the lock
+	locker.held = true
+	sessions.rows = 5
+	assert.NoError(t, j.RunOnce(context.Background()))
+	assert.Equal(t, int64(5), sessions.rows)
+}
+
+func TestJanitor_RunStopsOnCancel(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	config := NewConfig()
+	config.Interval = time.Millisecond
+
+	j := New(config, &fakeLocker{}, &fakePurger{}, &fakePurger{}, logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		j.Run(ctx)
+		close(done)
+	}()
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+}
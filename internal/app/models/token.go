@@ -3,10 +3,18 @@ package models
 import "time"
 
 type Session struct {
-	ID               string    `json:"id"`
-	UserEmail        string    `json:"user_email"`
-	RefreshTokenHash string    `json:"refresh_token_hash"`
-	IsRevoked        bool      `json:"is_revoked"`
-	CreatedAt        time.Time `json:"created_at"`
-	ExpiresAt        time.Time `json:"expires_at"`
+	ID               string     `json:"id"`
+	UserID           string     `json:"user_id"`
+	Audience         string     `json:"audience"`
+	Scope            string     `json:"scope"`
+	DPoPJKT          string     `json:"dpop_jkt"`
+	AccessTokenID    string     `json:"access_token_id"` // jti of the latest access token issued in the session
+	RefreshTokenHash string     `json:"refresh_token_hash"`
+	IsRevoked        bool       `json:"is_revoked"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty"`
+	RememberMe       bool       `json:"remember_me"`
+	AuthenticatedAt  time.Time  `json:"authenticated_at"` // sign-in time, carried over when the session is refreshed
+	LastUsedAt       time.Time  `json:"last_used_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+	ExpiresAt        time.Time  `json:"expires_at"`
 }
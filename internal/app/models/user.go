@@ -1,32 +1,74 @@
 package models
 
 import (
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/password"
 	validation "github.com/go-ozzo/ozzo-validation"
 	"github.com/go-ozzo/ozzo-validation/is"
 	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	passwordHasher password.Hasher = mustBcrypt(bcrypt.DefaultCost)
+	passwordPolicy                 = password.NewPolicy(password.NewPolicyConfig(), nil)
+	passwordPepper *password.Pepper
+)
+
+func mustBcrypt(cost int) password.Hasher {
+	h, err := password.NewBcrypt(cost)
+	if err != nil {
+		panic(err)
+	}
+
+	return h
+}
+
+// SetPasswordHasher replaces the hasher used by BeforeCreate and ComparePassword.
+func SetPasswordHasher(h password.Hasher) {
+	passwordHasher = h
+}
+
+// SetPasswordPepper sets the pepper applied to passwords before hashing. nil disables it.
+func SetPasswordPepper(p *password.Pepper) {
+	passwordPepper = p
+}
+
+// SetPasswordPolicy replaces the policy used by Validate.
+func SetPasswordPolicy(p *password.Policy) {
+	passwordPolicy = p
+}
+
 type User struct {
-	ID                string `json:"id"` // GUID
-	Email             string `json:"email"`
-	Password          string `json:"password,omitempty"`
-	EncryptedPassword string `json:"-"`
+	ID                string   `json:"id"` // GUID
+	Email             string   `json:"email"`
+	Password          string   `json:"password,omitempty"`
+	EncryptedPassword string   `json:"-"`
+	PepperVersion     int      `json:"-"`
+	Scopes            []string `json:"-"` // granted in addition to the configured default scopes
+	IsDisabled        bool     `json:"-"`
 }
 
 func (u *User) Validate() error {
 	return validation.ValidateStruct(u,
 		validation.Field(&u.Email, validation.Required, is.Email),
-		validation.Field(&u.Password, validation.By(requiredIf(u.EncryptedPassword == "")), validation.Length(6, 100)),
+		validation.Field(&u.Password, validation.By(requiredIf(u.EncryptedPassword == "")), validation.By(checkPassword(u.Email))),
 	)
 }
 
 func (u *User) BeforeCreate() error {
 	if len(u.Password) > 0 {
-		enc, err := encryptString(u.Password)
+		version := passwordPepper.CurrentVersion()
+
+		peppered, err := passwordPepper.Apply(u.Password, version)
+		if err != nil {
+			return err
+		}
+
+		enc, err := encryptString(peppered)
 		if err != nil {
 			return err
 		}
 		u.EncryptedPassword = enc
+		u.PepperVersion = version
 	}
 
 	return nil
@@ -37,16 +79,35 @@ func (u *User) Sanitize() {
 }
 
 func (u *User) ComparePassword(password string) bool {
-	return bcrypt.CompareHashAndPassword([]byte(u.EncryptedPassword), []byte(password)) == nil
+	peppered, err := passwordPepper.Apply(password, u.PepperVersion)
+	if err != nil {
+		return false
+	}
+
+	ok, err := passwordHasher.Verify(peppered, u.EncryptedPassword)
+	return err == nil && ok
+}
+
+// PasswordNeedsRehash reports whether EncryptedPassword was produced
+// with an outdated algorithm, parameters or pepper version.
+func (u *User) PasswordNeedsRehash() bool {
+	return u.PepperVersion != passwordPepper.CurrentVersion() ||
+		passwordHasher.NeedsRehash(u.EncryptedPassword)
 }
 
 func encryptString(s string) (string, error) {
-	b, err := bcrypt.GenerateFromPassword([]byte(s), bcrypt.MinCost)
-	if err != nil {
-		return "", err
-	}
+	return passwordHasher.Hash(s)
+}
 
-	return string(b), nil
+func checkPassword(email string) validation.RuleFunc {
+	return func(value interface{}) error {
+		s, _ := value.(string)
+		if s == "" {
+			return nil
+		}
+
+		return passwordPolicy.Check(s, email)
+	}
 }
 
 func requiredIf(cond bool) validation.RuleFunc {
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/password"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUser_PasswordNeedsRehashAfterPepperRotation(t *testing.T) {
+	hasher, pepper := passwordHasher, passwordPepper
+	t.Cleanup(func() { passwordHasher, passwordPepper = hasher, pepper })
+
+	SetPasswordHasher(mustBcrypt(4))
+
+	keys := []*password.PepperKey{{Version: 1, Secret: "one"}, {Version: 2, Secret: "two"}}
+	v1, err := password.NewPepper(&password.PepperConfig{CurrentVersion: 1, Keys: keys})
+	require.NoError(t, err)
+	v2, err := password.NewPepper(&password.PepperConfig{CurrentVersion: 2, Keys: keys})
+	require.NoError(t, err)
+
+	SetPasswordPepper(v1)
+	u := &User{Email: "user@example.org", Password: "Correct-Horse-7"}
+	require.NoError(t, u.BeforeCreate())
+	assert.Equal(t, 1, u.PepperVersion)
+	assert.False(t, u.PasswordNeedsRehash())
+
+	// rotated: the old hash still verifies but is due for a rehash
+	SetPasswordPepper(v2)
+	assert.True(t, u.ComparePassword("Correct-Horse-7"))
+	assert.True(t, u.PasswordNeedsRehash())
+
+	require.NoError(t, u.BeforeCreate())
+	assert.Equal(t, 2, u.PepperVersion)
+	assert.False(t, u.PasswordNeedsRehash())
+	assert.True(t, u.ComparePassword("Correct-Horse-7"))
+}
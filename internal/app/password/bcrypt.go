@@ -0,0 +1,65 @@
+package password
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// BcryptMaxBytes is the longest input bcrypt hashes; longer passwords are rejected.
+const BcryptMaxBytes = 72
+
+// Bcrypt hashes passwords with bcrypt. Hashes use the standard
+// modular crypt format ($2a$<cost>$...).
+type Bcrypt struct {
+	cost int
+}
+
+func NewBcrypt(cost int) (*Bcrypt, error) {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("%w: bcrypt cost %d is out of range [%d, %d]", ErrInvalidParams, cost, bcrypt.MinCost, bcrypt.MaxCost)
+	}
+
+	return &Bcrypt{
+		cost: cost,
+	}, nil
+}
+
+func (b *Bcrypt) Hash(plain string) (string, error) {
+	h, err := bcrypt.GenerateFromPassword([]byte(plain), b.cost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(h), nil
+}
+
+func (b *Bcrypt) Verify(plain string, encoded string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(plain))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (b *Bcrypt) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	if err != nil {
+		return true
+	}
+
+	return cost != b.cost
+}
+
+func (b *Bcrypt) Supports(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") ||
+		strings.HasPrefix(encoded, "$2b$") ||
+		strings.HasPrefix(encoded, "$2y$")
+}
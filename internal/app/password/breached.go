@@ -0,0 +1,153 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const sha1PrefixLen = 5
+
+// BreachedList is an offline set of breached password SHA-1 hashes, kept by
+// 5-character hash prefix the same way as the k-anonymity range API.
+type BreachedList struct {
+	ranges map[string]map[string]struct{}
+}
+
+// LoadBreachedList reads breached password hashes in the k-anonymity range
+// format of the HIBP downloader: files named by a 5-character SHA-1 prefix,
+// such as 21BD1.txt, with one "SUFFIX:count" line per hash of that prefix.
+// path is a directory of such files or a single one. A single file named
+// otherwise carries the prefix in a first column: "PREFIX:SUFFIX:count".
+// Empty lines and lines starting with '#' are ignored.
+func LoadBreachedList(path string) (*BreachedList, error) {
+	l := &BreachedList{
+		ranges: make(map[string]map[string]struct{}),
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		prefix, _ := rangePrefix(path)
+		if err := l.loadRange(path, prefix); err != nil {
+			return nil, err
+		}
+
+		return l, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+
+		name := filepath.Join(path, e.Name())
+		prefix, ok := rangePrefix(name)
+		if !ok {
+			return nil, fmt.Errorf("%s: not named by a sha1 prefix", name)
+		}
+
+		if err := l.loadRange(name, prefix); err != nil {
+			return nil, err
+		}
+	}
+
+	return l, nil
+}
+
+// rangePrefix returns the hash prefix a range file is named by.
+func rangePrefix(path string) (string, bool) {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if !isHex(name, sha1PrefixLen) {
+		return "", false
+	}
+
+	return strings.ToUpper(name), true
+}
+
+// loadRange reads the range file at path. An empty prefix means each line
+// starts with its own.
+func (l *BreachedList) loadRange(path string, prefix string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, ":")
+		p := prefix
+		if p == "" {
+			p, fields = fields[0], fields[1:]
+		}
+
+		if len(fields) != 2 || !isHex(p, sha1PrefixLen) || !isHex(fields[0], sha1.Size*2-sha1PrefixLen) {
+			return fmt.Errorf("%s:%d: invalid range line", path, n)
+		}
+
+		if _, err := strconv.ParseUint(fields[1], 10, 64); err != nil {
+			return fmt.Errorf("%s:%d: invalid count", path, n)
+		}
+
+		l.add(strings.ToUpper(p), strings.ToUpper(fields[0]))
+	}
+
+	return sc.Err()
+}
+
+func (l *BreachedList) Contains(plain string) bool {
+	sum := sha1.Sum([]byte(plain))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	suffixes, ok := l.ranges[hash[:sha1PrefixLen]]
+	if !ok {
+		return false
+	}
+
+	_, ok = suffixes[hash[sha1PrefixLen:]]
+	return ok
+}
+
+func (l *BreachedList) add(prefix string, suffix string) {
+	suffixes, ok := l.ranges[prefix]
+	if !ok {
+		suffixes = make(map[string]struct{})
+		l.ranges[prefix] = suffixes
+	}
+
+	suffixes[suffix] = struct{}{}
+}
+
+// isHex reports whether s is n hex digits.
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+
+	return true
+}
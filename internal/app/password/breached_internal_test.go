@@ -0,0 +1,91 @@
+package password
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadBreachedList(t *testing.T) {
+	testCases := []struct {
+		name     string
+		path     string
+		breached []string
+	}{
+		{
+			name:     "directory of ranges",
+			path:     "testdata/breached",
+			breached: []string{"Qwerty123!", "password"},
+		},
+		{
+			name:     "one range",
+			path:     "testdata/breached/D4F55.txt",
+			breached: []string{"Qwerty123!"},
+		},
+		{
+			name:     "ranges with the prefix first",
+			path:     "testdata/breached.txt",
+			breached: []string{"Qwerty123!", "password"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l, err := LoadBreachedList(tc.path)
+			assert.NoError(t, err)
+
+			for _, plain := range []string{"Qwerty123!", "password"} {
+				assert.Equal(t, slices.Contains(tc.breached, plain), l.Contains(plain), plain)
+			}
+			assert.False(t, l.Contains("Correct-Horse-42"))
+		})
+	}
+}
+
+func TestLoadBreachedList_Invalid(t *testing.T) {
+	testCases := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{
+			name:    "full hash in a range file",
+			file:    "D4F55.txt",
+			content: "D4F55DEC8C7BC9675182779E564FAE1327D30F9B:42\n",
+		},
+		{
+			name:    "no count",
+			file:    "D4F55.txt",
+			content: "DEC8C7BC9675182779E564FAE1327D30F9B\n",
+		},
+		{
+			name:    "no prefix",
+			file:    "breached.txt",
+			content: "DEC8C7BC9675182779E564FAE1327D30F9B:42\n",
+		},
+		{
+			name:    "not hex",
+			file:    "breached.txt",
+			content: "D4F55:XEC8C7BC9675182779E564FAE1327D30F9B:42\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tc.file)
+			assert.NoError(t, os.WriteFile(path, []byte(tc.content), 0o600))
+
+			_, err := LoadBreachedList(path)
+			assert.Error(t, err)
+		})
+	}
+
+	// a directory holds range files only
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "README"), nil, 0o600))
+	_, err := LoadBreachedList(dir)
+	assert.Error(t, err)
+}
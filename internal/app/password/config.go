@@ -0,0 +1,28 @@
+package password
+
+type Config struct {
+	Algorithm         string `toml:"algorithm"`
+	BcryptCost        int    `toml:"bcrypt_cost"`
+	Argon2Memory      uint32 `toml:"argon2_memory"`
+	Argon2Iterations  uint32 `toml:"argon2_iterations"`
+	Argon2Parallelism uint8  `toml:"argon2_parallelism"`
+	Argon2SaltLength  uint32 `toml:"argon2_salt_length"`
+	Argon2KeyLength   uint32 `toml:"argon2_key_length"`
+
+	Policy *PolicyConfig `toml:"policy"`
+	Pepper *PepperConfig `toml:"pepper"`
+}
+
+func NewConfig() *Config {
+	return &Config{
+		Algorithm:         AlgorithmArgon2id,
+		BcryptCost:        12,
+		Argon2Memory:      64 * 1024,
+		Argon2Iterations:  3,
+		Argon2Parallelism: 2,
+		Argon2SaltLength:  16,
+		Argon2KeyLength:   32,
+		Policy:            NewPolicyConfig(),
+		Pepper:            &PepperConfig{},
+	}
+}
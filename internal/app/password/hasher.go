@@ -0,0 +1,88 @@
+package password
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+)
+
+var (
+	ErrUnknownAlgorithm = errors.New("unknown password hashing algorithm")
+	ErrInvalidHash      = errors.New("invalid password hash")
+	ErrInvalidParams    = errors.New("invalid password hashing parameters")
+)
+
+// Hasher hashes passwords into encoded strings and verifies passwords against them.
+type Hasher interface {
+	Hash(plain string) (string, error)
+	Verify(plain string, encoded string) (bool, error)
+	// NeedsRehash reports whether encoded was produced with an outdated
+	// algorithm or parameters and should be replaced on the next successful login.
+	NeedsRehash(encoded string) bool
+}
+
+// scheme is a Hasher that can recognize its own encoded hashes.
+type scheme interface {
+	Hasher
+	Supports(encoded string) bool
+}
+
+// New returns a Hasher that hashes with the algorithm selected in config
+// and verifies hashes produced by any supported algorithm.
+func New(config *Config) (Hasher, error) {
+	b, err := NewBcrypt(config.BcryptCost)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := NewArgon2id(Argon2Params{
+		Memory:      config.Argon2Memory,
+		Iterations:  config.Argon2Iterations,
+		Parallelism: config.Argon2Parallelism,
+		SaltLength:  config.Argon2SaltLength,
+		KeyLength:   config.Argon2KeyLength,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch config.Algorithm {
+	case AlgorithmBcrypt:
+		return &chain{preferred: b, schemes: []scheme{b, a}}, nil
+	case AlgorithmArgon2id:
+		return &chain{preferred: a, schemes: []scheme{a, b}}, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownAlgorithm, config.Algorithm)
+	}
+}
+
+type chain struct {
+	preferred scheme
+	schemes   []scheme
+}
+
+func (c *chain) Hash(plain string) (string, error) {
+	return c.preferred.Hash(plain)
+}
+
+func (c *chain) Verify(plain string, encoded string) (bool, error) {
+	for _, s := range c.schemes {
+		if s.Supports(encoded) {
+			return s.Verify(plain, encoded)
+		}
+	}
+
+	return false, ErrInvalidHash
+}
+
+func (c *chain) NeedsRehash(encoded string) bool {
+	if !c.preferred.Supports(encoded) {
+		return true
+	}
+
+	return c.preferred.NeedsRehash(encoded)
+}
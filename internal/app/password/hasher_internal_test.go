@@ -0,0 +1,79 @@
+package password
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHasher_VerifyAndRehash(t *testing.T) {
+	config := NewConfig()
+	config.Argon2Memory = 1024
+	config.Argon2Iterations = 1
+
+	argon, err := New(config)
+	assert.NoError(t, err)
+
+	config.Algorithm = AlgorithmBcrypt
+	config.BcryptCost = 4
+	bcrypt, err := New(config)
+	assert.NoError(t, err)
+
+	legacy, err := bcrypt.Hash("password")
+	assert.NoError(t, err)
+
+	ok, err := argon.Verify("password", legacy)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, argon.NeedsRehash(legacy))
+
+	enc, err := argon.Hash("password")
+	assert.NoError(t, err)
+	assert.Contains(t, enc, "$argon2id$v=19$m=1024,t=1,p=2$")
+	assert.False(t, argon.NeedsRehash(enc))
+
+	ok, err = argon.Verify("wrong", enc)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	_, err = argon.Verify("password", "plain")
+	assert.ErrorIs(t, err, ErrInvalidHash)
+}
+
+func TestNew_InvalidParams(t *testing.T) {
+	testCases := []struct {
+		name   string
+		modify func(*Config)
+	}{
+		{"zero iterations", func(c *Config) { c.Argon2Iterations = 0 }},
+		{"zero parallelism", func(c *Config) { c.Argon2Parallelism = 0 }},
+		{"zero key length", func(c *Config) { c.Argon2KeyLength = 0 }},
+		{"bcrypt cost too low", func(c *Config) { c.BcryptCost = 3 }},
+		{"bcrypt cost too high", func(c *Config) { c.BcryptCost = 32 }},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := NewConfig()
+			tc.modify(config)
+
+			_, err := New(config)
+			assert.ErrorIs(t, err, ErrInvalidParams)
+		})
+	}
+}
+
+func TestArgon2id_VerifyInvalidParams(t *testing.T) {
+	a, err := NewArgon2id(Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32})
+	assert.NoError(t, err)
+
+	// would panic in argon2 if hashed with
+	for _, encoded := range []string{
+		"$argon2id$v=19$m=1024,t=1,p=0$c2FsdHNhbHRzYWx0c2FsdA$aGFzaGhhc2hoYXNoaGFzaA",
+		"$argon2id$v=19$m=1024,t=0,p=1$c2FsdHNhbHRzYWx0c2FsdA$aGFzaGhhc2hoYXNoaGFzaA",
+	} {
+		_, err := a.Verify("password", encoded)
+		assert.ErrorIs(t, err, ErrInvalidHash)
+		assert.True(t, a.NeedsRehash(encoded))
+	}
+}
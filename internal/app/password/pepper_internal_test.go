@@ -0,0 +1,89 @@
+package password
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPepper(t *testing.T) {
+	testCases := []struct {
+		name    string
+		config  *PepperConfig
+		isValid bool
+	}{
+		{
+			name:    "disabled",
+			config:  &PepperConfig{},
+			isValid: true,
+		},
+		{
+			name:    "current key",
+			config:  &PepperConfig{CurrentVersion: 1, Keys: []*PepperKey{{Version: 1, Secret: "one"}}},
+			isValid: true,
+		},
+		{
+			name:   "unknown current version",
+			config: &PepperConfig{CurrentVersion: 2, Keys: []*PepperKey{{Version: 1, Secret: "one"}}},
+		},
+		{
+			name:   "empty secret",
+			config: &PepperConfig{CurrentVersion: 1, Keys: []*PepperKey{{Version: 1}}},
+		},
+		{
+			name:   "non-positive version",
+			config: &PepperConfig{Keys: []*PepperKey{{Version: 0, Secret: "zero"}}},
+		},
+		{
+			name:   "duplicate version",
+			config: &PepperConfig{CurrentVersion: 1, Keys: []*PepperKey{{Version: 1, Secret: "one"}, {Version: 1, Secret: "other"}}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewPepper(tc.config)
+			if tc.isValid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+
+	_, err := NewPepper(&PepperConfig{CurrentVersion: 2, Keys: []*PepperKey{{Version: 1, Secret: "one"}}})
+	assert.ErrorIs(t, err, ErrUnknownPepperVersion)
+}
+
+func TestPepper_Apply(t *testing.T) {
+	p, err := NewPepper(&PepperConfig{CurrentVersion: 2, Keys: []*PepperKey{{Version: 1, Secret: "one"}, {Version: 2, Secret: "two"}}})
+	require.NoError(t, err)
+
+	plain, err := p.Apply("password", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "password", plain)
+
+	one, err := p.Apply("password", 1)
+	assert.NoError(t, err)
+	two, err := p.Apply("password", 2)
+	assert.NoError(t, err)
+	assert.NotEqual(t, "password", one)
+	assert.NotEqual(t, one, two)
+
+	again, _ := p.Apply("password", 1)
+	assert.Equal(t, one, again)
+
+	_, err = p.Apply("password", 3)
+	assert.ErrorIs(t, err, ErrUnknownPepperVersion)
+
+	var none *Pepper
+	assert.Equal(t, 0, none.CurrentVersion())
+
+	plain, err = none.Apply("password", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "password", plain)
+
+	_, err = none.Apply("password", 1)
+	assert.ErrorIs(t, err, ErrUnknownPepperVersion)
+}
@@ -0,0 +1,187 @@
+package password
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+const (
+	RuleMinLength   = "min_length"
+	RuleMaxLength   = "max_length"
+	RuleUppercase   = "uppercase"
+	RuleLowercase   = "lowercase"
+	RuleDigit       = "digit"
+	RuleSymbol      = "symbol"
+	RuleMaxRepeated = "max_repeated"
+	RuleEmail       = "email"
+	RuleBreached    = "breached"
+)
+
+type PolicyConfig struct {
+	MinLength            int    `toml:"min_length"`
+	MaxLength            int    `toml:"max_length"`
+	RequireUppercase     bool   `toml:"require_uppercase"`
+	RequireLowercase     bool   `toml:"require_lowercase"`
+	RequireDigit         bool   `toml:"require_digit"`
+	RequireSymbol        bool   `toml:"require_symbol"`
+	MaxRepeated          int    `toml:"max_repeated"` // 0 - no limit
+	ForbidEmailLocalPart bool   `toml:"forbid_email_local_part"`
+	BreachedListPath     string `toml:"breached_list"`
+}
+
+func NewPolicyConfig() *PolicyConfig {
+	return &PolicyConfig{
+		MinLength: 6,
+		MaxLength: 100,
+	}
+}
+
+// Violation describes a single password policy rule the password does not satisfy.
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Violations is returned by Policy.Check when one or more rules fail.
+type Violations []Violation
+
+func (v Violations) Error() string {
+	msgs := make([]string, 0, len(v))
+	for _, violation := range v {
+		msgs = append(msgs, violation.Message)
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+type Policy struct {
+	config   *PolicyConfig
+	breached *BreachedList
+	maxBytes int
+}
+
+// NewPolicy creates a password policy. breached may be nil, in which case
+// the breached-password rule is skipped.
+func NewPolicy(config *PolicyConfig, breached *BreachedList) *Policy {
+	return &Policy{
+		config:   config,
+		breached: breached,
+	}
+}
+
+// Check validates plain against every configured rule. email is used for the
+// email local-part rule and may be empty. The returned error, if any, is Violations.
+func (p *Policy) Check(plain string, email string) error {
+	var v Violations
+	length := len([]rune(plain))
+
+	if p.config.MinLength > 0 && length < p.config.MinLength {
+		v = append(v, Violation{RuleMinLength, fmt.Sprintf("must be at least %d characters long", p.config.MinLength)})
+	}
+
+	if p.config.MaxLength > 0 && length > p.config.MaxLength {
+		v = append(v, Violation{RuleMaxLength, fmt.Sprintf("must be at most %d characters long", p.config.MaxLength)})
+	} else if p.maxBytes > 0 && len(plain) > p.maxBytes {
+		v = append(v, Violation{RuleMaxLength, fmt.Sprintf("must be at most %d bytes long", p.maxBytes)})
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range plain {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+
+	if p.config.RequireUppercase && !upper {
+		v = append(v, Violation{RuleUppercase, "must contain an uppercase letter"})
+	}
+
+	if p.config.RequireLowercase && !lower {
+		v = append(v, Violation{RuleLowercase, "must contain a lowercase letter"})
+	}
+
+	if p.config.RequireDigit && !digit {
+		v = append(v, Violation{RuleDigit, "must contain a digit"})
+	}
+
+	if p.config.RequireSymbol && !symbol {
+		v = append(v, Violation{RuleSymbol, "must contain a symbol"})
+	}
+
+	if p.config.MaxRepeated > 0 && maxRun(plain) > p.config.MaxRepeated {
+		v = append(v, Violation{RuleMaxRepeated, fmt.Sprintf("must not repeat the same character more than %d times in a row", p.config.MaxRepeated)})
+	}
+
+	if p.config.ForbidEmailLocalPart && containsEmailLocalPart(plain, email) {
+		v = append(v, Violation{RuleEmail, "must not contain the email address"})
+	}
+
+	if p.breached != nil && p.breached.Contains(plain) {
+		v = append(v, Violation{RuleBreached, "has appeared in a data breach"})
+	}
+
+	if len(v) > 0 {
+		return v
+	}
+
+	return nil
+}
+
+// LimitBytes rejects passwords longer than n bytes, however few characters
+// they are, for hashers with a limit on their input such as bcrypt.
+func (p *Policy) LimitBytes(n int) {
+	p.maxBytes = n
+}
+
+func maxRun(s string) int {
+	var (
+		max, run int
+		prev     rune = -1
+	)
+
+	for _, r := range s {
+		if r == prev {
+			run++
+		} else {
+			run = 1
+			prev = r
+		}
+
+		if run > max {
+			max = run
+		}
+	}
+
+	return max
+}
+
+func containsEmailLocalPart(plain string, email string) bool {
+	local, _, _ := strings.Cut(email, "@")
+	if len(local) < 3 {
+		return false
+	}
+
+	return strings.Contains(strings.ToLower(plain), strings.ToLower(local))
+}
+
+// LoadPolicy creates a policy from config, loading the breached password list if configured.
+func LoadPolicy(config *PolicyConfig) (*Policy, error) {
+	var breached *BreachedList
+	if config.BreachedListPath != "" {
+		l, err := LoadBreachedList(config.BreachedListPath)
+		if err != nil {
+			return nil, err
+		}
+		breached = l
+	}
+
+	return NewPolicy(config, breached), nil
+}
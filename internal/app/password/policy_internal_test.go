@@ -0,0 +1,77 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicy_Check(t *testing.T) {
+	p, err := LoadPolicy(&PolicyConfig{
+		MinLength:            8,
+		MaxLength:            64,
+		RequireUppercase:     true,
+		RequireLowercase:     true,
+		RequireDigit:         true,
+		RequireSymbol:        true,
+		MaxRepeated:          2,
+		ForbidEmailLocalPart: true,
+		BreachedListPath:     "testdata/breached",
+	})
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		password string
+		rules    []string
+	}{
+		{
+			name:     "valid",
+			password: "Correct-Horse-42",
+		},
+		{
+			name:     "short and plain",
+			password: "aaab",
+			rules:    []string{RuleMinLength, RuleUppercase, RuleDigit, RuleSymbol, RuleMaxRepeated},
+		},
+		{
+			name:     "contains email",
+			password: "Andrey-Secret-42",
+			rules:    []string{RuleEmail},
+		},
+		{
+			name:     "breached",
+			password: "Qwerty123!",
+			rules:    []string{RuleBreached},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := p.Check(tc.password, "andrey@example.com")
+			if tc.rules == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			var rules []string
+			for _, v := range err.(Violations) {
+				rules = append(rules, v.Rule)
+			}
+			assert.Equal(t, tc.rules, rules)
+		})
+	}
+}
+
+func TestPolicy_LimitBytes(t *testing.T) {
+	p := NewPolicy(&PolicyConfig{MaxLength: 100}, nil)
+
+	// 40 characters, 80 bytes
+	long := strings.Repeat("ж", 40)
+	assert.NoError(t, p.Check(long, ""))
+
+	p.LimitBytes(BcryptMaxBytes)
+	err := p.Check(long, "")
+	assert.Equal(t, Violations{{RuleMaxLength, "must be at most 72 bytes long"}}, err)
+}
@@ -0,0 +1,148 @@
+// Package schema applies the embedded migrations. Versions are tracked in
+// schema_migrations and runs are serialized with a database lock, the same
+// way the migrate/migrate tool does it, so either can be used on a database.
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/andreyxaxa/rest_auth_svc/migrations"
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/database/sqlite"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+var upFileRe = regexp.MustCompile(`^(\d+)_(.+)\.up\.sql$`)
+
+// Migration is one schema version.
+type Migration struct {
+	Version uint
+	Name    string
+	Applied bool
+}
+
+type Migrator struct {
+	m     *migrate.Migrate
+	files fs.FS
+}
+
+// New connects to databaseURL with a connection of its own, closed by Close.
+// sqlite:// URLs get the SQLite migration set, anything else the Postgres one.
+func New(databaseURL string) (*Migrator, error) {
+	var files fs.FS = migrations.FS
+	if strings.HasPrefix(databaseURL, "sqlite://") {
+		sub, err := fs.Sub(migrations.SQLite, "sqlite")
+		if err != nil {
+			return nil, err
+		}
+		files = sub
+	}
+
+	return newMigrator(files, databaseURL)
+}
+
+func newMigrator(files fs.FS, databaseURL string) (*Migrator, error) {
+	src, err := iofs.New(files, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := migrate.NewWithSourceInstance("iofs", src, databaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{m: m, files: files}, nil
+}
+
+// Up applies every pending migration.
+func (m *Migrator) Up() error {
+	if err := m.m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
+
+	return nil
+}
+
+// Down rolls back the last n applied migrations.
+func (m *Migrator) Down(n int) error {
+	if n <= 0 {
+		return fmt.Errorf("cannot roll back %d migrations", n)
+	}
+
+	if err := m.m.Steps(-n); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
+
+	return nil
+}
+
+// Version returns the current schema version, 0 if nothing is applied. dirty
+// means a migration failed halfway and the schema needs fixing by hand.
+func (m *Migrator) Version() (version uint, dirty bool, err error) {
+	version, dirty, err = m.m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+
+	return version, dirty, err
+}
+
+// Status lists the known migrations, oldest first, marking those applied.
+func (m *Migrator) Status() ([]*Migration, error) {
+	current, _, err := m.Version()
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := listMigrations(m.files)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, migration := range list {
+		migration.Applied = migration.Version <= current
+	}
+
+	return list, nil
+}
+
+func (m *Migrator) Close() error {
+	srcErr, dbErr := m.m.Close()
+	return errors.Join(srcErr, dbErr)
+}
+
+func listMigrations(files fs.FS) ([]*Migration, error) {
+	entries, err := fs.ReadDir(files, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	list := []*Migration{}
+	for _, e := range entries {
+		match := upFileRe.FindStringSubmatch(e.Name())
+		if match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseUint(match[1], 10, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, &Migration{Version: uint(version), Name: match[2]})
+	}
+
+	slices.SortFunc(list, func(a, b *Migration) int {
+		return int(a.Version) - int(b.Version)
+	})
+
+	return list, nil
+}
@@ -0,0 +1,75 @@
+package schema
+
+import (
+	"path/filepath"
+	"strconv"
+	"testing"
+	"testing/fstest"
+
+	"github.com/andreyxaxa/rest_auth_svc/migrations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListMigrations(t *testing.T) {
+	files := fstest.MapFS{
+		"2_second.up.sql":   {},
+		"2_second.down.sql": {},
+		"1_first.up.sql":    {},
+		"1_first.down.sql":  {},
+		"migrations.go":     {},
+	}
+
+	list, err := listMigrations(files)
+	require.NoError(t, err)
+	assert.Equal(t, []*Migration{
+		{Version: 1, Name: "first"},
+		{Version: 2, Name: "second"},
+	}, list)
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	list, err := listMigrations(migrations.FS)
+	require.NoError(t, err)
+	require.NotEmpty(t, list)
+
+	// every up migration can be rolled back
+	for _, m := range list {
+		_, err := migrations.FS.Open(fmtDown(m))
+		assert.NoError(t, err, m.Name)
+	}
+}
+
+func fmtDown(m *Migration) string {
+	return strconv.FormatUint(uint64(m.Version), 10) + "_" + m.Name + ".down.sql"
+}
+
+func TestMigrator_SQLite(t *testing.T) {
+	m, err := New("sqlite://" + filepath.Join(t.TempDir(), "auth.db"))
+	require.NoError(t, err)
+	defer m.Close()
+
+	version, _, err := m.Version()
+	require.NoError(t, err)
+	assert.Zero(t, version)
+
+	require.NoError(t, m.Up())
+	require.NoError(t, m.Up()) // nothing left to apply
+
+	list, err := m.Status()
+	require.NoError(t, err)
+	require.NotEmpty(t, list)
+	for _, migration := range list {
+		assert.True(t, migration.Applied, migration.Name)
+	}
+
+	version, dirty, err := m.Version()
+	require.NoError(t, err)
+	assert.False(t, dirty)
+	assert.Equal(t, list[len(list)-1].Version, version)
+
+	require.NoError(t, m.Down(len(list)))
+	version, _, err = m.Version()
+	require.NoError(t, err)
+	assert.Zero(t, version)
+}
@@ -1,16 +1,61 @@
 package server
 
+import (
+	"time"
+
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/auth"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/dpop"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/janitor"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/password"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/session"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/token"
+)
+
+const (
+	denylistStorePostgres = "postgres"
+	denylistStoreMemory   = "memory"
+
+	sessionStoreDatabase = "database"
+	sessionStoreRedis    = "redis"
+)
+
 type Config struct {
-	Addr         string `toml:"addr"`
-	LogLevel     string `toml:"log_level"`
-	DatabaseURL  string `toml:"database_url"`
-	JwtSecretKey string `toml:"jwt_secret"`
+	Addr               string           `toml:"addr"`
+	LogLevel           string           `toml:"log_level"`
+	MetricsAddr        string           `toml:"metrics_addr"` // serves expvar /debug/vars when set
+	DatabaseURL        string           `toml:"database_url"`
+	AutoMigrate        bool             `toml:"auto_migrate"`  // apply pending migrations on startup
+	QueryTimeout       time.Duration    `toml:"query_timeout"` // bounds the storage calls of one request, 0 means no limit
+	JwtSecretKey       string           `toml:"jwt_secret"`
+	RefreshTokenKey    string           `toml:"refresh_token_key"`
+	RefreshTokenFormat string           `toml:"refresh_token_format"`
+	DefaultScopes      []string         `toml:"default_scopes"`
+	DenylistStore      string           `toml:"denylist_store"`
+	DenylistCacheTTL   time.Duration    `toml:"denylist_cache_ttl"`
+	SessionStore       string           `toml:"session_store"` // database or redis, users stay in the database either way
+	RedisURL           string           `toml:"redis_url"`
+	Password           *password.Config `toml:"password"`
+	Token              *token.Config    `toml:"token"`
+	DPoP               *dpop.Config     `toml:"dpop"`
+	Session            *session.Config  `toml:"session"`
+	Janitor            *janitor.Config  `toml:"janitor"`
 }
 
 func NewConfig() *Config {
 	return &Config{
-		Addr:     ":8080",
-		LogLevel: "debug",
+		Addr:               ":8080",
+		LogLevel:           "debug",
+		QueryTimeout:       5 * time.Second,
+		RefreshTokenFormat: auth.RefreshTokenFormatJWT,
+		DefaultScopes:      []string{scopeProfile, scopeAPIKeys},
+		DenylistStore:      denylistStorePostgres,
+		DenylistCacheTTL:   5 * time.Second,
+		SessionStore:       sessionStoreDatabase,
+		Password:           password.NewConfig(),
+		Token:              token.NewConfig(),
+		DPoP:               dpop.NewConfig(),
+		Session:            session.NewConfig(),
+		Janitor:            janitor.NewConfig(),
 		//JwtSecretKey: "mega-super-ultra-xxl-turbo-secret-key123",
 	}
 }
@@ -1,7 +1,54 @@
 package server
 
-import "errors"
+import (
+	"errors"
+	"net/http"
+
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/apperr"
+	validation "github.com/go-ozzo/ozzo-validation"
+)
 
 var (
-	errIncorrectEmailOrPassword = errors.New("incorrect email or password")
+	errMalformedRequest    = apperr.New(apperr.ErrInvalidInput, "malformed_request", "malformed request body")
+	errInsufficientScope   = apperr.New(apperr.ErrForbidden, "insufficient_scope", "insufficient scope")
+	errAccessTokenRequired = apperr.New(apperr.ErrInvalidInput, "access_token_required", "access token required")
 )
+
+// statusCode maps an error to the HTTP status of its kind. Failed model
+// validation is a validation error; errors of no kind are internal.
+func statusCode(err error) int {
+	var errs validation.Errors
+
+	switch {
+	case errors.Is(err, apperr.ErrInvalidInput):
+		return http.StatusBadRequest
+	case errors.Is(err, apperr.ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, apperr.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, apperr.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, apperr.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, apperr.ErrValidation), errors.As(err, &errs):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// errorCode returns the code of err, or the generic one of status if err
+// has none.
+func errorCode(err error, status int) string {
+	var appErr *apperr.Error
+	if status != http.StatusInternalServerError && errors.As(err, &appErr) && appErr.Code != "" {
+		return appErr.Code
+	}
+
+	switch status {
+	case http.StatusUnprocessableEntity:
+		return "validation_failed"
+	default:
+		return "internal_error"
+	}
+}
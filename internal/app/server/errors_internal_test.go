@@ -0,0 +1,37 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/auth"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/models"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/session"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusCode(t *testing.T) {
+	invalid := (&models.User{Email: "not an email"}).Validate()
+
+	tests := []struct {
+		err  error
+		code int
+	}{
+		{errMalformedRequest, http.StatusBadRequest},
+		{auth.ErrInvalidRefreshToken, http.StatusUnauthorized},
+		{session.ErrIdleTimeout, http.StatusUnauthorized},
+		{auth.ErrUserDisabled, http.StatusForbidden},
+		{storage.ErrRecordNotFound, http.StatusNotFound},
+		{fmt.Errorf("create: %w", storage.ErrAlreadyExists), http.StatusConflict},
+		{auth.ErrTooManySessions, http.StatusConflict},
+		{invalid, http.StatusUnprocessableEntity},
+		{errors.New("pq: connection refused"), http.StatusInternalServerError},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.code, statusCode(tc.err), tc.err.Error())
+	}
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/apperr"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/auth"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/models"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/token"
+	"github.com/gorilla/mux"
+)
+
+const (
+	scopeProfile = "profile"
+	scopeAPIKeys = "api_keys"
+	scopeAdmin   = "admin"
+)
+
+type ctxKey int8
+
+const (
+	ctxKeyUser ctxKey = iota
+	ctxKeyScopes
+	ctxKeyClaims
+)
+
+// setQueryTimeout puts the configured deadline on the request context, which is
+// passed down to every storage call the request makes. Queries are also
+// cancelled when the client goes away.
+func (s *server) setQueryTimeout(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.queryTimeout <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), s.queryTimeout)
+		defer cancel()
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// authenticateUser accepts a bearer access token, a DPoP-bound access token
+// (Authorization: DPoP ...) or a personal API key (Authorization: ApiKey ...)
+// and puts the user and the scopes granted to the credentials into the request context,
+// along with the claims when an access token was used.
+func (s *server) authenticateUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var (
+			u         *models.User
+			claims    *token.UserClaims
+			scopes    []string
+			challenge string
+			err       error
+		)
+
+		scheme, credentials, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		switch {
+		case strings.EqualFold(scheme, "Bearer") && credentials != "":
+			u, claims, err = s.auth.AuthenticateAccessToken(r.Context(), credentials, false, s.proof(r))
+			challenge = `Bearer error="invalid_token"`
+		case strings.EqualFold(scheme, "DPoP") && credentials != "":
+			u, claims, err = s.auth.AuthenticateAccessToken(r.Context(), credentials, true, s.proof(r))
+			challenge = `DPoP error="invalid_token"`
+		case strings.EqualFold(scheme, "ApiKey") && credentials != "":
+			u, scopes, err = s.auth.AuthenticateAPIKey(r.Context(), credentials)
+			challenge = "ApiKey"
+		default:
+			err = auth.ErrNotAuthenticated
+			challenge = "Bearer"
+		}
+
+		// only rejected credentials get a challenge; a disabled user is
+		// forbidden and a failing storage is an internal error
+		if err != nil {
+			if errors.Is(err, apperr.ErrUnauthorized) {
+				w.Header().Set("WWW-Authenticate", challenge)
+			}
+			s.error(w, r, err)
+			return
+		}
+
+		if claims != nil {
+			scopes = claims.Scopes()
+		}
+
+		ctx := context.WithValue(r.Context(), ctxKeyUser, u)
+		ctx = context.WithValue(ctx, ctxKeyScopes, scopes)
+		if claims != nil {
+			ctx = context.WithValue(ctx, ctxKeyClaims, claims)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requireScopes rejects requests whose credentials were not granted every one of scopes.
+// It must run after authenticateUser.
+func (s *server) requireScopes(scopes ...string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			granted, _ := r.Context().Value(ctxKeyScopes).([]string)
+			if !token.HasScopes(granted, scopes...) {
+				w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+token.FormatScope(scopes)+`"`)
+				s.error(w, r, errInsufficientScope)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -1,36 +1,58 @@
 package server
 
 import (
-	"bytes"
 	"encoding/json"
 	"errors"
 	"net/http"
-	"net/smtp"
+	"strings"
 	"time"
 
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/auth"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/dpop"
 	"github.com/andreyxaxa/rest_auth_svc/internal/app/models"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/password"
 	"github.com/andreyxaxa/rest_auth_svc/internal/app/storage"
 	"github.com/andreyxaxa/rest_auth_svc/internal/app/token"
+	validation "github.com/go-ozzo/ozzo-validation"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type server struct {
-	logger     *logrus.Logger
-	router     *mux.Router
-	storage    storage.Storage
-	tokenMaker *token.JWTMaker
+	logger       *logrus.Logger
+	router       *mux.Router
+	storage      storage.Storage
+	auth         *auth.Service
+	dpopConfig   *dpop.Config
+	queryTimeout time.Duration
 }
 
-func newServer(storage storage.Storage, secretKey string) *server {
+func newServer(store storage.Storage, tokenMaker token.Maker, config *Config) *server {
+	// refresh token digests are keyed with jwt_secret unless a separate key is configured
+	refreshTokenKey := config.RefreshTokenKey
+	if refreshTokenKey == "" {
+		refreshTokenKey = config.JwtSecretKey
+	}
+
 	s := &server{
-		logger:     logrus.New(),
-		router:     mux.NewRouter(),
-		storage:    storage,
-		tokenMaker: token.NewJWTMaker(secretKey),
+		logger:       logrus.New(),
+		router:       mux.NewRouter(),
+		storage:      store,
+		dpopConfig:   config.DPoP,
+		queryTimeout: config.QueryTimeout,
 	}
 
+	s.auth = auth.New(&auth.Config{
+		RefreshTokenKey:    refreshTokenKey,
+		RefreshTokenFormat: config.RefreshTokenFormat,
+		DefaultScopes:      config.DefaultScopes,
+		DenylistInStorage:  config.DenylistStore == denylistStorePostgres,
+		DenylistCacheTTL:   config.DenylistCacheTTL,
+		Token:              config.Token,
+		DPoP:               config.DPoP,
+		Session:            config.Session,
+	}, store, tokenMaker, s.logger)
+
 	s.configureRouter()
 
 	return s
@@ -41,12 +63,27 @@ func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *server) configureRouter() {
+	s.router.Use(s.setQueryTimeout)
+
 	s.router.HandleFunc("/users", s.handleUsersCreate()).Methods("POST")
 	s.router.HandleFunc("/login", s.handleUsersLogin()).Methods("POST")
 
 	s.router.HandleFunc("/tokens/{id}", s.handleUsersTokens()).Methods("GET")             // 1 - выдача токенов по GUID
 	s.router.HandleFunc("/tokens/refresh", s.handleUsersTokensRefresh()).Methods("POST")  // 2 - рефреш пары токенов
 	s.router.HandleFunc("/tokens/renew", s.handleUsersRenewAccessToken()).Methods("POST") // 3 - обновление Access токена
+
+	me := s.router.PathPrefix("/me").Subrouter()
+	me.Use(s.authenticateUser)
+	me.Handle("", s.requireScopes(scopeProfile)(s.handleWhoami())).Methods("GET")
+	me.Handle("/api-keys", s.requireScopes(scopeAPIKeys)(s.handleAPIKeysList())).Methods("GET")
+	me.Handle("/api-keys", s.requireScopes(scopeAPIKeys)(s.handleAPIKeysCreate())).Methods("POST")
+	me.Handle("/api-keys/{id}", s.requireScopes(scopeAPIKeys)(s.handleAPIKeysDelete())).Methods("DELETE")
+	me.Handle("/password", s.requireScopes(scopeProfile)(s.handlePasswordChange())).Methods("POST")
+	me.Handle("/logout", s.handleLogout()).Methods("POST")
+
+	admin := s.router.PathPrefix("/admin").Subrouter()
+	admin.Use(s.authenticateUser, s.requireScopes(scopeAdmin))
+	admin.Handle("/users/{id}/disable", s.handleAdminUserDisable()).Methods("POST")
 }
 
 // ----- handlers
@@ -56,7 +93,7 @@ func (s *server) handleUsersCreate() http.HandlerFunc {
 		req := &UserCreateReq{}
 
 		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
-			s.error(w, r, http.StatusBadRequest, err)
+			s.error(w, r, errMalformedRequest)
 			return
 		}
 
@@ -65,8 +102,8 @@ func (s *server) handleUsersCreate() http.HandlerFunc {
 			Password: req.Password,
 		}
 
-		if err := s.storage.User().Create(u); err != nil {
-			s.error(w, r, http.StatusUnprocessableEntity, err)
+		if err := s.storage.User().Create(r.Context(), u); err != nil {
+			s.error(w, r, err)
 			return
 		}
 
@@ -84,268 +121,313 @@ func (s *server) handleUsersLogin() http.HandlerFunc {
 		req := &UserLoginReq{}
 
 		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
-			s.error(w, r, http.StatusBadRequest, err)
+			s.error(w, r, errMalformedRequest)
 			return
 		}
 
-		u, err := s.storage.User().FindByEmail(req.Email)
-		if err != nil || !u.ComparePassword(req.Password) {
-			s.error(w, r, http.StatusUnauthorized, errIncorrectEmailOrPassword)
-			return
-		}
-
-		// creating tokens
-		accessToken, accessClaims, err := s.tokenMaker.CreateToken(u.ID, u.Email, r.RemoteAddr, 15*time.Minute)
+		tokens, err := s.auth.Login(r.Context(), &auth.LoginParams{
+			Client:     s.client(r),
+			Email:      req.Email,
+			Password:   req.Password,
+			Audience:   req.Audience,
+			Scope:      req.Scope,
+			RememberMe: req.RememberMe,
+		})
 		if err != nil {
-			s.error(w, r, http.StatusInternalServerError, err)
+			s.error(w, r, err)
 			return
 		}
 
-		refreshToken, refreshClaims, err := s.tokenMaker.CreateToken(u.ID, u.Email, r.RemoteAddr, 24*time.Hour)
+		s.respond(w, r, http.StatusOK, newUserLoginRes(tokens))
+	}
+}
+
+func (s *server) handleUsersTokens() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tokens, err := s.auth.IssueForUser(r.Context(), &auth.IssueParams{
+			Client:   s.client(r),
+			UserID:   mux.Vars(r)["id"],
+			Audience: r.URL.Query().Get("audience"),
+			Scope:    r.URL.Query().Get("scope"),
+		})
 		if err != nil {
-			s.error(w, r, http.StatusInternalServerError, err)
+			s.error(w, r, err)
 			return
 		}
 
-		refreshTokenHash, err := hashRefreshToken(refreshToken)
-		if err != nil {
-			s.error(w, r, http.StatusInternalServerError, err)
+		s.respond(w, r, http.StatusOK, newUserLoginRes(tokens))
+	}
+}
+
+func (s *server) handleUsersTokensRefresh() http.HandlerFunc {
+	type request struct {
+		ID           string `json:"id"`
+		Sess_id      string `json:"session_id"`
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := &request{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			s.error(w, r, errMalformedRequest)
 			return
 		}
 
-		session, err := s.storage.Token().CreateSession(&models.Session{
-			ID:               refreshClaims.RegisteredClaims.ID,
-			UserEmail:        u.Email,
-			RefreshTokenHash: string(refreshTokenHash),
-			IsRevoked:        false,
-			ExpiresAt:        refreshClaims.RegisteredClaims.ExpiresAt.Time,
+		tokens, err := s.auth.Refresh(r.Context(), &auth.RefreshParams{
+			Client:       s.client(r),
+			UserID:       req.ID,
+			SessionID:    req.Sess_id,
+			RefreshToken: req.RefreshToken,
 		})
 		if err != nil {
-			s.error(w, r, http.StatusInternalServerError, err)
+			s.error(w, r, err)
 			return
 		}
 
-		res := &UserLoginRes{
-			SessionID:             session.ID,
-			AccessToken:           accessToken,
-			RefreshToken:          refreshToken,
-			AccessTokenExpiresAt:  accessClaims.RegisteredClaims.ExpiresAt.Time,
-			RefreshTokenExpiresAt: refreshClaims.RegisteredClaims.ExpiresAt.Time,
-			User: UserCreateRes{
-				ID:    u.ID,
-				Email: u.Email,
-			},
-		}
-
-		s.respond(w, r, http.StatusOK, res)
+		s.respond(w, r, http.StatusOK, newUserLoginRes(tokens))
 	}
 }
 
-func (s *server) handleUsersTokens() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		vars := mux.Vars(r)
-		id, ok := vars["id"]
-		if !ok {
-			s.error(w, r, http.StatusInternalServerError, errors.New("user with this id not found"))
-		}
+func (s *server) handleUsersRenewAccessToken() http.HandlerFunc {
+	type request struct {
+		RefreshToken string `json:"refresh_token"`
+	}
 
-		u, err := s.storage.User().FindByID(id)
-		if err != nil {
-			s.error(w, r, http.StatusUnauthorized, errIncorrectEmailOrPassword)
-			return
-		}
+	type response struct {
+		AccessToken          string    `json:"access_token"`
+		AccessTokenExpiresAt time.Time `json:"access_token_expires_at"`
+		TokenType            string    `json:"token_type"`
+		Scope                string    `json:"scope"`
+	}
 
-		// creating tokens
-		accessToken, accessClaims, err := s.tokenMaker.CreateToken(u.ID, u.Email, r.RemoteAddr, 15*time.Minute)
-		if err != nil {
-			s.error(w, r, http.StatusInternalServerError, err)
-			return
-		}
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := &request{}
 
-		refreshToken, refreshClaims, err := s.tokenMaker.CreateToken(u.ID, u.Email, r.RemoteAddr, 24*time.Hour)
-		if err != nil {
-			s.error(w, r, http.StatusInternalServerError, err)
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			s.error(w, r, errMalformedRequest)
 			return
 		}
 
-		session, err := s.storage.Token().CreateSession(&models.Session{
-			ID:               refreshClaims.RegisteredClaims.ID,
-			UserEmail:        u.Email,
-			RefreshTokenHash: refreshToken,
-			IsRevoked:        false,
-			ExpiresAt:        refreshClaims.RegisteredClaims.ExpiresAt.Time,
+		tokens, err := s.auth.Renew(r.Context(), &auth.RenewParams{
+			Client:       s.client(r),
+			RefreshToken: req.RefreshToken,
 		})
 		if err != nil {
-			s.error(w, r, http.StatusInternalServerError, err)
+			s.error(w, r, err)
 			return
 		}
 
-		res := &UserLoginRes{
-			SessionID:             session.ID,
-			AccessToken:           accessToken,
-			RefreshToken:          refreshToken,
-			AccessTokenExpiresAt:  accessClaims.RegisteredClaims.ExpiresAt.Time,
-			RefreshTokenExpiresAt: refreshClaims.RegisteredClaims.ExpiresAt.Time,
-			User: UserCreateRes{
-				ID:    u.ID,
-				Email: u.Email,
-			},
+		res := &response{
+			AccessToken:          tokens.AccessToken,
+			AccessTokenExpiresAt: tokens.AccessTokenExpiresAt,
+			TokenType:            tokens.TokenType,
+			Scope:                token.FormatScope(tokens.Scopes),
 		}
 
 		s.respond(w, r, http.StatusOK, res)
 	}
 }
 
-func (s *server) handleUsersTokensRefresh() http.HandlerFunc {
-	type request struct {
-		ID      string `json:"id"`
-		Sess_id string `json:"session_id"`
+func (s *server) handleWhoami() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u := r.Context().Value(ctxKeyUser).(*models.User)
+
+		res := &UserCreateRes{
+			ID:    u.ID,
+			Email: u.Email,
+		}
+
+		s.respond(w, r, http.StatusOK, res)
 	}
+}
 
+func (s *server) handleAPIKeysList() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		req := &request{}
-		err := json.NewDecoder(r.Body).Decode(req)
-		if err != nil {
-			s.respond(w, r, http.StatusBadRequest, err)
-			return
-		}
+		u := r.Context().Value(ctxKeyUser).(*models.User)
 
-		u, err := s.storage.User().FindByID(req.ID)
+		keys, err := s.storage.APIKey().ListByUser(r.Context(), u.ID)
 		if err != nil {
-			s.error(w, r, http.StatusUnauthorized, errIncorrectEmailOrPassword)
+			s.error(w, r, err)
 			return
 		}
 
-		session, err := s.storage.Token().GetSession(req.Sess_id)
-		if err != nil {
-			s.error(w, r, http.StatusInternalServerError, err)
+		s.respond(w, r, http.StatusOK, keys)
+	}
+}
+
+func (s *server) handleAPIKeysCreate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := &APIKeyCreateReq{}
+
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			s.error(w, r, errMalformedRequest)
 			return
 		}
 
-		err = s.storage.Token().DeleteSession(session.ID)
-		if err != nil {
-			s.respond(w, r, http.StatusInternalServerError, err)
+		u := r.Context().Value(ctxKeyUser).(*models.User)
+		granted := r.Context().Value(ctxKeyScopes).([]string)
+
+		// a key cannot get scopes the credentials creating it do not have
+		if !token.HasScopes(granted, req.Scopes...) {
+			s.error(w, r, auth.ErrInvalidScope)
 			return
 		}
 
-		// creating tokens
-		accessToken, accessClaims, err := s.tokenMaker.CreateToken(u.ID, u.Email, r.RemoteAddr, 15*time.Minute)
-		if err != nil {
-			s.error(w, r, http.StatusInternalServerError, err)
-			return
+		scopes := req.Scopes
+		if len(scopes) == 0 {
+			scopes = granted
 		}
 
-		refreshToken, refreshClaims, err := s.tokenMaker.CreateToken(u.ID, u.Email, r.RemoteAddr, 24*time.Hour)
+		key, lookup, secretHash, err := token.NewAPIKey()
 		if err != nil {
-			s.error(w, r, http.StatusInternalServerError, err)
+			s.error(w, r, err)
 			return
 		}
 
-		refreshTokenHash, err := hashRefreshToken(refreshToken)
-		if err != nil {
-			s.respond(w, r, http.StatusInternalServerError, err)
-			return
+		k := &models.APIKey{
+			UserID:     u.ID,
+			Name:       req.Name,
+			Prefix:     lookup,
+			SecretHash: secretHash,
+			Scopes:     scopes,
+			ExpiresAt:  req.ExpiresAt,
 		}
 
-		session, err = s.storage.Token().CreateSession(&models.Session{
-			ID:               refreshClaims.RegisteredClaims.ID,
-			UserEmail:        u.Email,
-			RefreshTokenHash: refreshTokenHash,
-			IsRevoked:        false,
-			ExpiresAt:        refreshClaims.RegisteredClaims.ExpiresAt.Time,
-		})
-		if err != nil {
-			s.error(w, r, http.StatusInternalServerError, err)
+		if err := s.storage.APIKey().Create(r.Context(), k); err != nil {
+			s.error(w, r, err)
 			return
 		}
 
-		res := &UserLoginRes{
-			SessionID:             session.ID,
-			AccessToken:           accessToken,
-			RefreshToken:          refreshToken,
-			AccessTokenExpiresAt:  accessClaims.RegisteredClaims.ExpiresAt.Time,
-			RefreshTokenExpiresAt: refreshClaims.RegisteredClaims.ExpiresAt.Time,
-			User: UserCreateRes{
-				ID:    u.ID,
-				Email: u.Email,
-			},
+		res := &APIKeyCreateRes{
+			Key:    key,
+			APIKey: k,
 		}
 
-		s.respond(w, r, http.StatusOK, res)
+		s.respond(w, r, http.StatusCreated, res)
 	}
 }
 
-func (s *server) handleUsersRenewAccessToken() http.HandlerFunc {
-	type request struct {
-		RefreshToken string `json:"refresh_token"`
-	}
+func (s *server) handleAPIKeysDelete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u := r.Context().Value(ctxKeyUser).(*models.User)
 
-	type response struct {
-		AccessToken          string    `json:"access_token"`
-		AccessTokenExpiresAt time.Time `json:"access_token_expires_at"`
+		err := s.storage.APIKey().Delete(r.Context(), mux.Vars(r)["id"], u.ID)
+		if err != nil {
+			s.error(w, r, err)
+			return
+		}
+
+		s.respond(w, r, http.StatusNoContent, nil)
 	}
+}
 
+// handleLogout revokes the session the access token was issued in and denies the token itself.
+func (s *server) handleLogout() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		req := &request{}
+		u := r.Context().Value(ctxKeyUser).(*models.User)
 
-		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
-			s.error(w, r, http.StatusBadRequest, err)
+		claims, ok := r.Context().Value(ctxKeyClaims).(*token.UserClaims)
+		if !ok {
+			s.error(w, r, errAccessTokenRequired)
 			return
 		}
 
-		refreshClaims, err := s.tokenMaker.VerifyToken(req.RefreshToken)
-		if err != nil {
-			s.error(w, r, http.StatusUnauthorized, err)
+		if err := s.auth.Logout(r.Context(), u, claims); err != nil {
+			s.error(w, r, err)
 			return
 		}
 
-		session, err := s.storage.Token().GetSession(refreshClaims.RegisteredClaims.ID)
-		if err != nil {
-			s.error(w, r, http.StatusInternalServerError, err)
-			return
-		}
+		s.respond(w, r, http.StatusNoContent, nil)
+	}
+}
 
-		if refreshClaims.IP != r.RemoteAddr {
-			err = s.sendEmailWarning(session.UserEmail, r.RemoteAddr) // email-warning
-			if err != nil {
-				s.logger.Debug(err)
-			}
-		}
+// handlePasswordChange sets a new password and signs the user out everywhere.
+func (s *server) handlePasswordChange() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u := r.Context().Value(ctxKeyUser).(*models.User)
 
-		if session.IsRevoked {
-			s.error(w, r, http.StatusUnauthorized, errors.New("session revoked"))
+		req := &PasswordChangeReq{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			s.error(w, r, errMalformedRequest)
 			return
 		}
 
-		if session.UserEmail != refreshClaims.Email {
-			s.error(w, r, http.StatusUnauthorized, errors.New("invalid session"))
+		claims, _ := r.Context().Value(ctxKeyClaims).(*token.UserClaims)
+		if err := s.auth.ChangePassword(r.Context(), u, req.CurrentPassword, req.NewPassword, claims); err != nil {
+			s.error(w, r, err)
 			return
 		}
 
-		accessToken, accessClaims, err := s.tokenMaker.CreateToken(
-			refreshClaims.ID,
-			refreshClaims.Email,
-			refreshClaims.IP,
-			15*time.Minute,
-		)
-		if err != nil {
-			s.error(w, r, http.StatusInternalServerError, err)
-		}
+		s.respond(w, r, http.StatusNoContent, nil)
+	}
+}
 
-		res := &response{
-			AccessToken:          accessToken,
-			AccessTokenExpiresAt: accessClaims.RegisteredClaims.ExpiresAt.Time,
+// handleAdminUserDisable blocks a user from signing in and revokes their sessions.
+func (s *server) handleAdminUserDisable() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.auth.DisableUser(r.Context(), mux.Vars(r)["id"]); err != nil {
+			s.error(w, r, err)
+			return
 		}
 
-		s.respond(w, r, http.StatusOK, res)
+		s.respond(w, r, http.StatusNoContent, nil)
 	}
-
 }
 
 // ----- helpers
 
-func (s *server) error(w http.ResponseWriter, r *http.Request, code int, err error) {
-	s.respond(w, r, code, map[string]string{"error": err.Error()})
+func newUserLoginRes(tokens *auth.Tokens) *UserLoginRes {
+	return &UserLoginRes{
+		SessionID:             tokens.Session.ID,
+		AccessToken:           tokens.AccessToken,
+		RefreshToken:          tokens.RefreshToken,
+		AccessTokenExpiresAt:  tokens.AccessTokenExpiresAt,
+		RefreshTokenExpiresAt: tokens.Session.ExpiresAt,
+		TokenType:             tokens.TokenType,
+		Scope:                 token.FormatScope(tokens.Scopes),
+		User: UserCreateRes{
+			ID:    tokens.User.ID,
+			Email: tokens.User.Email,
+		},
+	}
+}
+
+// error responds with an application/problem+json document for err, with
+// the status of its kind. Internal errors are logged and described to the
+// client by status and code only.
+func (s *server) error(w http.ResponseWriter, r *http.Request, err error) {
+	code := statusCode(err)
+
+	res := &ProblemRes{
+		Type:     "about:blank",
+		Title:    http.StatusText(code),
+		Status:   code,
+		Detail:   err.Error(),
+		Instance: r.URL.Path,
+		Code:     errorCode(err, code),
+	}
+
+	switch code {
+	case http.StatusInternalServerError:
+		s.logger.Error(err)
+		res.Detail = ""
+	case http.StatusUnprocessableEntity:
+		var errs validation.Errors
+		if errors.As(err, &errs) {
+			res.Errors = make(map[string]string, len(errs))
+			for field, fieldErr := range errs {
+				res.Errors[field] = fieldErr.Error()
+			}
+
+			var violations password.Violations
+			if errors.As(errs["password"], &violations) {
+				res.Violations = violations
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	s.respond(w, r, code, res)
 }
 
 func (s *server) respond(w http.ResponseWriter, r *http.Request, code int, data interface{}) {
@@ -355,51 +437,33 @@ func (s *server) respond(w http.ResponseWriter, r *http.Request, code int, data
 	}
 }
 
-func hashRefreshToken(token string) (string, error) {
-	const maxLen = 72
-	var hashes []string
-
-	for i := 0; i < len(token); i += maxLen {
-		end := i + maxLen
-		if end > len(token) {
-			end = len(token)
-		}
-		part := token[i:end]
-
-		hash, err := bcrypt.GenerateFromPassword([]byte(part), bcrypt.DefaultCost)
-		if err != nil {
-			return "", err
-		}
-		hashes = append(hashes, string(hash))
+// requestURL returns the URL a DPoP proof for r must name in htu.
+func (s *server) requestURL(r *http.Request) string {
+	if s.dpopConfig.BaseURL != "" {
+		return strings.TrimRight(s.dpopConfig.BaseURL, "/") + r.URL.Path
 	}
 
-	var buffer bytes.Buffer
-	for _, hash := range hashes {
-		buffer.WriteString(hash)
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
 	}
 
-	return buffer.String(), nil
+	return scheme + "://" + r.Host + r.URL.Path
 }
 
-func (s *server) sendEmailWarning(email string, ip string) error {
-	auth := smtp.PlainAuth("",
-		"workauthml@gmail.com",
-		"z0of123laopL3rv",
-		"smtp.gmail.com",
-	)
-
-	msg := "Warning: Another IP\nNew IP - " + ip
-
-	err := smtp.SendMail(
-		"smtp.gmail.com:587",
-		auth,
-		"workauthml@gmail.com",
-		[]string{email},
-		[]byte(msg),
-	)
-	if err != nil {
-		return err
+// proof returns the DPoP proof sent with r.
+func (s *server) proof(r *http.Request) auth.Proof {
+	return auth.Proof{
+		JWT:    r.Header.Get(dpop.HeaderName),
+		Method: r.Method,
+		URL:    s.requestURL(r),
 	}
+}
 
-	return nil
+// client describes the client making r.
+func (s *server) client(r *http.Request) auth.Client {
+	return auth.Client{
+		IP:    r.RemoteAddr,
+		Proof: s.proof(r),
+	}
 }
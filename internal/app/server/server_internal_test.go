@@ -1,16 +1,288 @@
 package server
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
 	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/models"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/schema"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/storage"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/storage/memstore"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/storage/redisstore"
+	sqlitestorage "github.com/andreyxaxa/rest_auth_svc/internal/app/storage/sqlite"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/token"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-func TestServer_HandleTest(t *testing.T) {
-	// s := newServer() //тестовое хранилище
+func newTestServer(t *testing.T) *server {
+	t.Helper()
+
+	return newTestServerWith(t, memstore.New())
+}
+
+func newTestServerWith(t *testing.T, store storage.Storage) *server {
+	t.Helper()
+
+	config := NewConfig()
+	config.JwtSecretKey = "test-secret"
+	config.DenylistStore = denylistStoreMemory
+
+	tokenMaker, err := token.NewMaker(config.Token, config.JwtSecretKey)
+	require.NoError(t, err)
+
+	return newServer(store, tokenMaker, config)
+}
+
+func (s *server) do(t *testing.T, method string, path string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+
+	b := &bytes.Buffer{}
+	require.NoError(t, json.NewEncoder(b).Encode(body))
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(method, path, b))
+
+	return rec
+}
+
+func TestServer_HandleUsersTokensRefresh(t *testing.T) {
+	testServerHandleUsersTokensRefresh(t, newTestServer(t))
+}
+
+func TestServer_HandleUsersTokensRefreshRedis(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	defer client.Close()
+
+	testServerHandleUsersTokensRefresh(t, newTestServerWith(t, redisstore.Wrap(memstore.New(), redisstore.NewTokenRepository(client))))
+}
+
+func testServerHandleUsersTokensRefresh(t *testing.T, s *server) {
+
+	creds := map[string]string{"email": "user@example.org", "password": "Correct-Horse-7"}
+	require.Equal(t, http.StatusCreated, s.do(t, "POST", "/users", creds).Code)
+
+	rec := s.do(t, "POST", "/login", creds)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	login := &UserLoginRes{}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(login))
+
+	refresh := map[string]string{
+		"id":            login.User.ID,
+		"session_id":    login.SessionID,
+		"refresh_token": login.RefreshToken,
+	}
+
+	rec = s.do(t, "POST", "/tokens/refresh", refresh)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rotated := &UserLoginRes{}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(rotated))
+	assert.NotEqual(t, login.SessionID, rotated.SessionID)
+
+	// the old refresh token went with its session
+	assert.NotEqual(t, http.StatusOK, s.do(t, "POST", "/tokens/refresh", refresh).Code)
+}
+
+func TestServer_HandleMePasswordSQLite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth.db")
+
+	m, err := schema.New("sqlite://" + path)
+	require.NoError(t, err)
+	require.NoError(t, m.Up())
+	require.NoError(t, m.Close())
+
+	db, err := sqlitestorage.Open(path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	// revocations write the database denylist while the sessions are locked
+	config := NewConfig()
+	config.JwtSecretKey = "test-secret"
+	tokenMaker, err := token.NewMaker(config.Token, config.JwtSecretKey)
+	require.NoError(t, err)
+	s := newServer(sqlitestorage.New(db), tokenMaker, config)
+
+	creds := map[string]string{"email": "user@example.org", "password": "Correct-Horse-7"}
+	require.Equal(t, http.StatusCreated, s.do(t, "POST", "/users", creds).Code)
+
+	rec := s.do(t, "POST", "/login", creds)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	login := &UserLoginRes{}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(login))
+
+	withToken := func(method string, path string, body any) int {
+		b := &bytes.Buffer{}
+		require.NoError(t, json.NewEncoder(b).Encode(body))
+
+		req := httptest.NewRequest(method, path, b)
+		req.Header.Set("Authorization", "Bearer "+login.AccessToken)
+
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+
+		return rec.Code
+	}
+
+	change := map[string]string{"current_password": "Correct-Horse-7", "new_password": "Battery-Staple-8"}
+	require.Equal(t, http.StatusNoContent, withToken("POST", "/me/password", change))
+
+	assert.Equal(t, http.StatusUnauthorized, withToken("GET", "/me", nil))
+}
+
+func TestServer_Errors(t *testing.T) {
+	s := newTestServer(t)
+
+	creds := map[string]string{"email": "user@example.org", "password": "Correct-Horse-7"}
+	require.Equal(t, http.StatusCreated, s.do(t, "POST", "/users", creds).Code)
+
+	rec := s.do(t, "POST", "/users", creds)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{
+		"type": "about:blank",
+		"title": "Conflict",
+		"status": 409,
+		"detail": "record already exists",
+		"instance": "/users",
+		"code": "already_exists"
+	}`, rec.Body.String())
+
+	rec = s.do(t, "POST", "/tokens/refresh", "not an object")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.JSONEq(t, `{
+		"type": "about:blank",
+		"title": "Bad Request",
+		"status": 400,
+		"detail": "malformed request body",
+		"instance": "/tokens/refresh",
+		"code": "malformed_request"
+	}`, rec.Body.String())
+
+	rec = s.do(t, "POST", "/users", map[string]string{"email": "not an email", "password": "Correct-Horse-7"})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	problem := &ProblemRes{}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(problem))
+	assert.Equal(t, "validation_failed", problem.Code)
+	assert.Contains(t, problem.Errors, "email")
+	assert.NotContains(t, problem.Errors, "password")
+
+	rec = s.do(t, "POST", "/login", map[string]string{"email": creds["email"], "password": "Wrong-Horse-7"})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"code":"invalid_credentials"`)
+
+	rec = s.do(t, "POST", "/login", creds)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	login := &UserLoginRes{}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(login))
+
+	// an unknown session is a bad token, not a server error
+	refresh := map[string]string{"id": login.User.ID, "session_id": "unknown", "refresh_token": login.RefreshToken}
+	assert.Equal(t, http.StatusUnauthorized, s.do(t, "POST", "/tokens/refresh", refresh).Code)
+}
+
+// brokenUsers is a storage whose users cannot be looked up by id.
+type brokenUsers struct {
+	storage.Storage
+}
+
+func (b *brokenUsers) User() storage.UserRepository {
+	return &brokenUserRepository{UserRepository: b.Storage.User()}
+}
+
+type brokenUserRepository struct {
+	storage.UserRepository
+}
+
+func (r *brokenUserRepository) FindByID(ctx context.Context, id string) (*models.User, error) {
+	return nil, errors.New("connection reset")
+}
+
+func TestServer_AuthenticateUserErrors(t *testing.T) {
+	testCases := []struct {
+		name          string
+		authorization string
+		disable       bool
+		broken        bool
+		wantStatus    int
+		wantCode      string
+		wantChallenge string
+	}{
+		{
+			name:          "no credentials",
+			wantStatus:    http.StatusUnauthorized,
+			wantCode:      "not_authenticated",
+			wantChallenge: "Bearer",
+		},
+		{
+			name:          "invalid token",
+			authorization: "Bearer not-a-token",
+			wantStatus:    http.StatusUnauthorized,
+			wantCode:      "not_authenticated",
+			wantChallenge: `Bearer error="invalid_token"`,
+		},
+		{
+			name:       "disabled user",
+			disable:    true,
+			wantStatus: http.StatusForbidden,
+			wantCode:   "user_disabled",
+		},
+		{
+			name:       "storage failure",
+			broken:     true,
+			wantStatus: http.StatusInternalServerError,
+			wantCode:   "internal_error",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := memstore.New()
+			s := newTestServerWith(t, store)
+
+			creds := map[string]string{"email": "user@example.org", "password": "Correct-Horse-7"}
+			require.Equal(t, http.StatusCreated, s.do(t, "POST", "/users", creds).Code)
+
+			rec := s.do(t, "POST", "/login", creds)
+			require.Equal(t, http.StatusOK, rec.Code)
+
+			login := &UserLoginRes{}
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(login))
+
+			if tc.disable {
+				require.NoError(t, store.User().SetDisabled(context.Background(), login.User.ID, true))
+			}
+			if tc.broken {
+				s = newTestServerWith(t, &brokenUsers{Storage: store})
+			}
 
-	// rec := httptest.NewRecorder()
-	// req := httptest.NewRequest("GET", "/test", nil)
+			authorization := tc.authorization
+			if authorization == "" && (tc.disable || tc.broken) {
+				authorization = "Bearer " + login.AccessToken
+			}
 
-	// s.handleTest().ServeHTTP(rec, req)
+			req := httptest.NewRequest("GET", "/me", nil)
+			if authorization != "" {
+				req.Header.Set("Authorization", authorization)
+			}
+			rec = httptest.NewRecorder()
+			s.ServeHTTP(rec, req)
 
-	// assert.Equal(t, rec.Body.String(), "hello")
+			assert.Equal(t, tc.wantStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), `"code":"`+tc.wantCode+`"`)
+			assert.Equal(t, tc.wantChallenge, rec.Header().Get("WWW-Authenticate"))
+		})
+	}
 }
@@ -1,22 +1,188 @@
 package server
 
 import (
+	"context"
 	"database/sql"
+	"expvar"
+	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/auth"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/janitor"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/models"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/password"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/schema"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/session"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/storage"
 	sqlstorage "github.com/andreyxaxa/rest_auth_svc/internal/app/storage/postgre"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/storage/redisstore"
+	sqlitestorage "github.com/andreyxaxa/rest_auth_svc/internal/app/storage/sqlite"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/token"
+	"github.com/redis/go-redis/v9"
 )
 
 func Start(config *Config) error {
-	db, err := newDB(config.DatabaseURL)
+	if config.RefreshTokenFormat != auth.RefreshTokenFormatJWT && config.RefreshTokenFormat != auth.RefreshTokenFormatOpaque {
+		return fmt.Errorf("unknown refresh token format %q", config.RefreshTokenFormat)
+	}
+
+	if config.DenylistStore != denylistStorePostgres && config.DenylistStore != denylistStoreMemory {
+		return fmt.Errorf("unknown denylist store %q", config.DenylistStore)
+	}
+
+	if config.SessionStore != sessionStoreDatabase && config.SessionStore != sessionStoreRedis {
+		return fmt.Errorf("unknown session store %q", config.SessionStore)
+	}
+
+	if config.Session.LimitPolicy != session.LimitReject && config.Session.LimitPolicy != session.LimitEvictOldest {
+		return fmt.Errorf("unknown session limit policy %q", config.Session.LimitPolicy)
+	}
+
+	if config.Janitor.Enabled && (config.Janitor.Interval <= 0 || config.Janitor.BatchSize <= 0) {
+		return fmt.Errorf("janitor interval and batch_size must be positive, got %s and %d", config.Janitor.Interval, config.Janitor.BatchSize)
+	}
+
+	hasher, err := password.New(config.Password)
+	if err != nil {
+		return err
+	}
+	models.SetPasswordHasher(hasher)
+
+	pepper, err := password.NewPepper(config.Password.Pepper)
 	if err != nil {
 		return err
 	}
+	models.SetPasswordPepper(pepper)
 
-	storage := sqlstorage.New(db)
-	srv := newServer(storage, config.JwtSecretKey)
+	policy, err := password.LoadPolicy(config.Password.Policy)
+	if err != nil {
+		return err
+	}
+	// peppered passwords reach the hasher as a short digest
+	if config.Password.Algorithm == password.AlgorithmBcrypt && pepper.CurrentVersion() == 0 {
+		policy.LimitBytes(password.BcryptMaxBytes)
+	}
+	models.SetPasswordPolicy(policy)
+
+	tokenMaker, err := token.NewMaker(config.Token, config.JwtSecretKey)
+	if err != nil {
+		return err
+	}
+
+	if config.AutoMigrate {
+		if err := migrateUp(config.DatabaseURL); err != nil {
+			return fmt.Errorf("auto migrate: %w", err)
+		}
+	}
+
+	store, err := newStorage(config.DatabaseURL)
+	if err != nil {
+		return err
+	}
+
+	var st storage.Storage = store
+	if config.SessionStore == sessionStoreRedis {
+		tokens, err := newRedisTokenRepository(config.RedisURL)
+		if err != nil {
+			return err
+		}
+
+		st = redisstore.Wrap(store, tokens)
+	}
+
+	srv := newServer(st, tokenMaker, config)
+
+	// stopped on SIGINT or SIGTERM, which also cancels the janitor's queries
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if config.Janitor.Enabled {
+		j := janitor.New(config.Janitor, store, st.Token(), srv.auth.Denylist(), srv.logger)
+		go j.Run(ctx)
+	}
+
+	if config.MetricsAddr != "" {
+		go func() {
+			if err := http.ListenAndServe(config.MetricsAddr, expvar.Handler()); err != nil {
+				srv.logger.Error(err)
+			}
+		}()
+	}
+
+	httpServer := &http.Server{Addr: config.Addr, Handler: srv.router}
+	shutdown := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		shutdown <- httpServer.Shutdown(shutdownCtx)
+	}()
+
+	if err := httpServer.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+
+	// wait for in-flight requests
+	return <-shutdown
+}
+
+// shutdownTimeout bounds how long in-flight requests may finish on shutdown.
+const shutdownTimeout = 10 * time.Second
+
+func migrateUp(databaseURL string) error {
+	m, err := schema.New(databaseURL)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	return m.Up()
+}
+
+// lockingStorage is a storage the janitor can also take its lock in.
+type lockingStorage interface {
+	storage.Storage
+	janitor.Locker
+}
+
+// newStorage picks the backend by the database_url scheme: sqlite://<path>
+// for SQLite, anything else is handed to Postgres.
+func newStorage(databaseURL string) (lockingStorage, error) {
+	if path, ok := strings.CutPrefix(databaseURL, "sqlite://"); ok {
+		db, err := sqlitestorage.Open(path)
+		if err != nil {
+			return nil, err
+		}
+
+		return sqlitestorage.New(db), nil
+	}
+
+	db, err := newDB(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return sqlstorage.New(db), nil
+}
+
+func newRedisTokenRepository(redisURL string) (*redisstore.TokenRepository, error) {
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, err
+	}
+
+	client := redis.NewClient(opts)
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		return nil, err
+	}
 
-	return http.ListenAndServe(config.Addr, srv.router)
+	return redisstore.NewTokenRepository(client), nil
 }
 
 func newDB(databaseURL string) (*sql.DB, error) {
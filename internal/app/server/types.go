@@ -1,6 +1,11 @@
 package server
 
-import "time"
+import (
+	"time"
+
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/models"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/password"
+)
 
 type UserCreateReq struct {
 	Email    string `json:"email"`
@@ -12,9 +17,26 @@ type UserCreateRes struct {
 	Email string `json:"email"`
 }
 
+// ProblemRes is an RFC 7807 problem details document. Code is stable for
+// clients to branch on; Errors and Violations come with failed validation.
+type ProblemRes struct {
+	Type       string               `json:"type"`
+	Title      string               `json:"title"`
+	Status     int                  `json:"status"`
+	Detail     string               `json:"detail,omitempty"`
+	Instance   string               `json:"instance,omitempty"`
+	Code       string               `json:"code"`
+	Errors     map[string]string    `json:"errors,omitempty"`     // per field
+	Violations []password.Violation `json:"violations,omitempty"` // password policy rules the password broke
+}
+
 type UserLoginReq struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	Audience string `json:"audience,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+	// RememberMe selects the longer session policy.
+	RememberMe bool `json:"remember_me,omitempty"`
 }
 
 type UserLoginRes struct {
@@ -23,6 +45,8 @@ type UserLoginRes struct {
 	RefreshToken          string        `json:"refresh_token"`
 	AccessTokenExpiresAt  time.Time     `json:"access_token_expires_at"`
 	RefreshTokenExpiresAt time.Time     `json:"refresh_token_expires_at"`
+	TokenType             string        `json:"token_type"`
+	Scope                 string        `json:"scope"`
 	User                  UserCreateRes `json:"user"`
 }
 
@@ -34,3 +58,19 @@ type RenewAccessTokenRes struct {
 	AccessToken          string    `json:"access_token"`
 	AccessTokenExpiresAt time.Time `json:"access_token_expires_at"`
 }
+
+type PasswordChangeReq struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type APIKeyCreateReq struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type APIKeyCreateRes struct {
+	Key string `json:"key"` // shown only once
+	*models.APIKey
+}
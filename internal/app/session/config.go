@@ -0,0 +1,43 @@
+package session
+
+import "time"
+
+const (
+	LimitReject      = "reject"
+	LimitEvictOldest = "evict_oldest"
+)
+
+// Config holds the default session policy and the longer one selected by "remember me".
+type Config struct {
+	Policy
+	RememberMe *Policy `toml:"remember_me"`
+	// MaxPerUser caps the active sessions of a user, 0 means no limit.
+	MaxPerUser int `toml:"max_per_user"`
+	// LimitPolicy says what happens to a sign-in over the limit: LimitReject
+	// refuses it, LimitEvictOldest revokes the user's oldest sessions.
+	LimitPolicy string `toml:"limit_policy"`
+}
+
+func NewConfig() *Config {
+	return &Config{
+		Policy: Policy{
+			IdleTimeout:      2 * time.Hour,
+			AbsoluteLifetime: 24 * time.Hour,
+		},
+		RememberMe: &Policy{
+			IdleTimeout:      14 * 24 * time.Hour,
+			AbsoluteLifetime: 90 * 24 * time.Hour,
+			RefreshTTL:       30 * 24 * time.Hour,
+		},
+		LimitPolicy: LimitReject,
+	}
+}
+
+// PolicyFor returns the policy for sessions started with or without "remember me".
+func (c *Config) PolicyFor(rememberMe bool) *Policy {
+	if rememberMe && c.RememberMe != nil {
+		return c.RememberMe
+	}
+
+	return &c.Policy
+}
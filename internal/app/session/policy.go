@@ -0,0 +1,51 @@
+package session
+
+import (
+	"time"
+
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/apperr"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/models"
+)
+
+var (
+	ErrIdleTimeout     = apperr.New(apperr.ErrUnauthorized, "session_idle_timeout", "session idle timeout exceeded")
+	ErrLifetimeExpired = apperr.New(apperr.ErrUnauthorized, "session_lifetime_exceeded", "session lifetime exceeded")
+)
+
+// Policy limits how long a session lives. Zero durations disable the limit.
+type Policy struct {
+	// IdleTimeout ends a session not renewed or refreshed for this long.
+	IdleTimeout time.Duration `toml:"idle_timeout"`
+	// AbsoluteLifetime ends a session this long after the user signed in,
+	// however many times its refresh token was rotated.
+	AbsoluteLifetime time.Duration `toml:"absolute_lifetime"`
+	// RefreshTTL overrides the audience's refresh token lifetime when set.
+	RefreshTTL time.Duration `toml:"refresh_ttl"`
+}
+
+// Check reports whether s may still be used at now.
+func (p *Policy) Check(s *models.Session, now time.Time) error {
+	if p.AbsoluteLifetime > 0 && now.After(s.AuthenticatedAt.Add(p.AbsoluteLifetime)) {
+		return ErrLifetimeExpired
+	}
+
+	if p.IdleTimeout > 0 && now.After(s.LastUsedAt.Add(p.IdleTimeout)) {
+		return ErrIdleTimeout
+	}
+
+	return nil
+}
+
+// RefreshTTLAt returns the lifetime of a refresh token issued at now for a session
+// whose user signed in at authenticatedAt; refreshTTL is the audience's default.
+func (p *Policy) RefreshTTLAt(authenticatedAt, now time.Time, refreshTTL time.Duration) time.Duration {
+	if p.RefreshTTL > 0 {
+		refreshTTL = p.RefreshTTL
+	}
+
+	if p.AbsoluteLifetime > 0 {
+		refreshTTL = min(refreshTTL, authenticatedAt.Add(p.AbsoluteLifetime).Sub(now))
+	}
+
+	return refreshTTL
+}
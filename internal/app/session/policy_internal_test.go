@@ -0,0 +1,38 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicy_Check(t *testing.T) {
+	now := time.Now()
+	p := &Policy{IdleTimeout: 2 * time.Hour, AbsoluteLifetime: 24 * time.Hour}
+
+	assert.NoError(t, p.Check(&models.Session{AuthenticatedAt: now.Add(-23 * time.Hour), LastUsedAt: now.Add(-time.Hour)}, now))
+	assert.ErrorIs(t, p.Check(&models.Session{AuthenticatedAt: now.Add(-time.Hour), LastUsedAt: now.Add(-3 * time.Hour)}, now), ErrIdleTimeout)
+	assert.ErrorIs(t, p.Check(&models.Session{AuthenticatedAt: now.Add(-25 * time.Hour), LastUsedAt: now}, now), ErrLifetimeExpired)
+
+	assert.NoError(t, (&Policy{}).Check(&models.Session{}, now))
+}
+
+func TestPolicy_RefreshTTLAt(t *testing.T) {
+	now := time.Now()
+	p := &Policy{AbsoluteLifetime: 24 * time.Hour}
+
+	assert.Equal(t, 24*time.Hour, p.RefreshTTLAt(now, now, 48*time.Hour))
+	assert.Equal(t, 4*time.Hour, p.RefreshTTLAt(now.Add(-20*time.Hour), now, 24*time.Hour))
+
+	p.RefreshTTL = time.Hour
+	assert.Equal(t, time.Hour, p.RefreshTTLAt(now, now, 24*time.Hour))
+}
+
+func TestConfig_PolicyFor(t *testing.T) {
+	c := NewConfig()
+
+	assert.Equal(t, &c.Policy, c.PolicyFor(false))
+	assert.Equal(t, c.RememberMe, c.PolicyFor(true))
+}
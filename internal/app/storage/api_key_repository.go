@@ -0,0 +1,16 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/models"
+)
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, k *models.APIKey) error
+	FindByPrefix(ctx context.Context, prefix string) (*models.APIKey, error)
+	ListByUser(ctx context.Context, userID string) ([]*models.APIKey, error)
+	Delete(ctx context.Context, id string, userID string) error
+	UpdateLastUsed(ctx context.Context, id string, t time.Time) error
+}
@@ -0,0 +1,15 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// DenylistRepository holds the IDs (jti) of access tokens revoked before they expire.
+type DenylistRepository interface {
+	// Add denies jti until expiresAt, after which the token is rejected as expired anyway.
+	Add(ctx context.Context, jti string, expiresAt time.Time) error
+	Contains(ctx context.Context, jti string) (bool, error)
+	// DeleteExpired deletes up to limit entries that expired before now and returns how many it deleted.
+	DeleteExpired(ctx context.Context, now time.Time, limit int) (int64, error)
+}
@@ -1,7 +1,10 @@
 package storage
 
-import "errors"
+import "github.com/andreyxaxa/rest_auth_svc/internal/app/apperr"
 
 var (
-	ErrRecordNotFound = errors.New("record not found")
+	ErrRecordNotFound = apperr.New(apperr.ErrNotFound, "not_found", "record not found")
+	// ErrAlreadyExists is returned by Create when a unique field, such as
+	// a user's email or an API key prefix, is already taken.
+	ErrAlreadyExists = apperr.New(apperr.ErrConflict, "already_exists", "record already exists")
 )
@@ -0,0 +1,88 @@
+package memstore
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/models"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/storage"
+	"github.com/google/uuid"
+)
+
+type APIKeyRepository struct {
+	storage *Storage
+}
+
+func (r *APIKeyRepository) Create(ctx context.Context, k *models.APIKey) error {
+	if err := k.Validate(); err != nil {
+		return err
+	}
+
+	defer r.storage.lock()()
+
+	for _, other := range r.storage.data.apiKeys {
+		if other.Prefix == k.Prefix {
+			return storage.ErrAlreadyExists
+		}
+	}
+
+	k.ID = uuid.New().String()
+	k.CreatedAt = time.Now()
+
+	r.storage.data.apiKeys[k.ID] = copyAPIKey(k)
+
+	return nil
+}
+
+func (r *APIKeyRepository) FindByPrefix(ctx context.Context, prefix string) (*models.APIKey, error) {
+	defer r.storage.lock()()
+
+	for _, k := range r.storage.data.apiKeys {
+		if k.Prefix == prefix {
+			return copyAPIKey(k), nil
+		}
+	}
+
+	return nil, storage.ErrRecordNotFound
+}
+
+func (r *APIKeyRepository) ListByUser(ctx context.Context, userID string) ([]*models.APIKey, error) {
+	defer r.storage.lock()()
+
+	keys := []*models.APIKey{}
+	for _, k := range r.storage.data.apiKeys {
+		if k.UserID == userID {
+			keys = append(keys, copyAPIKey(k))
+		}
+	}
+
+	slices.SortFunc(keys, func(a, b *models.APIKey) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	return keys, nil
+}
+
+func (r *APIKeyRepository) Delete(ctx context.Context, id string, userID string) error {
+	defer r.storage.lock()()
+
+	k, ok := r.storage.data.apiKeys[id]
+	if !ok || k.UserID != userID {
+		return storage.ErrRecordNotFound
+	}
+
+	delete(r.storage.data.apiKeys, id)
+
+	return nil
+}
+
+func (r *APIKeyRepository) UpdateLastUsed(ctx context.Context, id string, t time.Time) error {
+	defer r.storage.lock()()
+
+	if k, ok := r.storage.data.apiKeys[id]; ok {
+		k.LastUsedAt = &t
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package memstore
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DenylistRepository keeps denied token IDs in process memory. Entries are
+// lost on restart and not shared between instances.
+type DenylistRepository struct {
+	mu        sync.Mutex
+	entries   map[string]time.Time
+	nextSweep int
+}
+
+func NewDenylistRepository() *DenylistRepository {
+	return &DenylistRepository{
+		entries:   make(map[string]time.Time),
+		nextSweep: 1024,
+	}
+}
+
+func (r *DenylistRepository) Add(ctx context.Context, jti string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries[jti] = expiresAt
+
+	// drop expired entries whenever the map has doubled since the last sweep
+	if len(r.entries) >= r.nextSweep {
+		now := time.Now()
+		for id, exp := range r.entries {
+			if !now.Before(exp) {
+				delete(r.entries, id)
+			}
+		}
+		r.nextSweep = max(2*len(r.entries), 1024)
+	}
+
+	return nil
+}
+
+func (r *DenylistRepository) Contains(ctx context.Context, jti string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	exp, ok := r.entries[jti]
+	return ok && time.Now().Before(exp), nil
+}
+
+func (r *DenylistRepository) DeleteExpired(ctx context.Context, now time.Time, limit int) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int64
+	for id, exp := range r.entries {
+		if n >= int64(limit) {
+			break
+		}
+
+		if exp.Before(now) {
+			delete(r.entries, id)
+			n++
+		}
+	}
+
+	return n, nil
+}
@@ -0,0 +1,29 @@
+package memstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDenylistRepository(t *testing.T) {
+	ctx := context.Background()
+	r := NewDenylistRepository()
+
+	assert.NoError(t, r.Add(ctx, "denied", time.Now().Add(time.Minute)))
+	assert.NoError(t, r.Add(ctx, "expired", time.Now().Add(-time.Second)))
+
+	denied, err := r.Contains(ctx, "denied")
+	assert.NoError(t, err)
+	assert.True(t, denied)
+
+	denied, err = r.Contains(ctx, "expired")
+	assert.NoError(t, err)
+	assert.False(t, denied)
+
+	denied, err = r.Contains(ctx, "unknown")
+	assert.NoError(t, err)
+	assert.False(t, denied)
+}
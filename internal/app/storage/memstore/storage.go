@@ -0,0 +1,136 @@
+package memstore
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/models"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/storage"
+)
+
+var _ storage.Storage = (*Storage)(nil)
+
+// Storage keeps everything in process memory. It is meant for tests and
+// local runs: nothing survives a restart. A single lock serializes all
+// operations, and a transaction holds it until it ends.
+type Storage struct {
+	mu       *sync.Mutex
+	data     *data
+	inTx     bool
+	denylist *DenylistRepository
+}
+
+type data struct {
+	users    map[string]*models.User
+	sessions map[string]*models.Session
+	apiKeys  map[string]*models.APIKey
+}
+
+func New() *Storage {
+	return &Storage{
+		mu: &sync.Mutex{},
+		data: &data{
+			users:    make(map[string]*models.User),
+			sessions: make(map[string]*models.Session),
+			apiKeys:  make(map[string]*models.APIKey),
+		},
+		denylist: NewDenylistRepository(),
+	}
+}
+
+func (s *Storage) User() storage.UserRepository {
+	return &UserRepository{storage: s}
+}
+
+func (s *Storage) Token() storage.TokenRepository {
+	return &TokenRepository{storage: s}
+}
+
+func (s *Storage) APIKey() storage.APIKeyRepository {
+	return &APIKeyRepository{storage: s}
+}
+
+// Denylist entries are kept apart from the rest and are not rolled back with a transaction.
+func (s *Storage) Denylist() storage.DenylistRepository {
+	return s.denylist
+}
+
+func (s *Storage) WithTx(ctx context.Context, fn func(storage.Storage) error) error {
+	if s.inTx {
+		return fn(s)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := s.data.clone()
+
+	if err := fn(&Storage{mu: s.mu, data: s.data, inTx: true, denylist: s.denylist}); err != nil {
+		*s.data = *snapshot
+		return err
+	}
+
+	return nil
+}
+
+// lock takes the storage lock unless the calling transaction already holds it.
+func (s *Storage) lock() func() {
+	if s.inTx {
+		return func() {}
+	}
+
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+func (d *data) clone() *data {
+	c := &data{
+		users:    make(map[string]*models.User, len(d.users)),
+		sessions: make(map[string]*models.Session, len(d.sessions)),
+		apiKeys:  make(map[string]*models.APIKey, len(d.apiKeys)),
+	}
+
+	for id, u := range d.users {
+		c.users[id] = copyUser(u)
+	}
+	for id, session := range d.sessions {
+		c.sessions[id] = copySession(session)
+	}
+	for id, k := range d.apiKeys {
+		c.apiKeys[id] = copyAPIKey(k)
+	}
+
+	return c
+}
+
+// Records are copied in and out so that callers never share them with the store.
+
+func copyUser(u *models.User) *models.User {
+	c := *u
+	c.Scopes = slices.Clone(u.Scopes)
+	return &c
+}
+
+func copySession(session *models.Session) *models.Session {
+	c := *session
+	if session.RevokedAt != nil {
+		t := *session.RevokedAt
+		c.RevokedAt = &t
+	}
+	return &c
+}
+
+func copyAPIKey(k *models.APIKey) *models.APIKey {
+	c := *k
+	c.Scopes = slices.Clone(k.Scopes)
+	if k.ExpiresAt != nil {
+		t := *k.ExpiresAt
+		c.ExpiresAt = &t
+	}
+	if k.LastUsedAt != nil {
+		t := *k.LastUsedAt
+		c.LastUsedAt = &t
+	}
+	return &c
+}
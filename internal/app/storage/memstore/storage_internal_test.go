@@ -0,0 +1,32 @@
+package memstore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/models"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/storage"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/storage/storagetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return New()
+	})
+}
+
+func TestStorage_CopiesRecords(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+
+	session := &models.Session{ID: "a"}
+	_, err := s.Token().CreateSession(ctx, session)
+	assert.NoError(t, err)
+
+	session.IsRevoked = true
+
+	stored, err := s.Token().GetSession(ctx, "a")
+	assert.NoError(t, err)
+	assert.False(t, stored.IsRevoked)
+}
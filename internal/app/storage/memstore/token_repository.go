@@ -0,0 +1,154 @@
+package memstore
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/models"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/storage"
+)
+
+type TokenRepository struct {
+	storage *Storage
+}
+
+func (t *TokenRepository) CreateSession(ctx context.Context, session *models.Session) (*models.Session, error) {
+	defer t.storage.lock()()
+
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = time.Now()
+	}
+
+	t.storage.data.sessions[session.ID] = copySession(session)
+
+	return session, nil
+}
+
+func (t *TokenRepository) GetSession(ctx context.Context, id string) (*models.Session, error) {
+	defer t.storage.lock()()
+
+	session, ok := t.storage.data.sessions[id]
+	if !ok {
+		return nil, storage.ErrRecordNotFound
+	}
+
+	return copySession(session), nil
+}
+
+// GetSessionForUpdate needs no row lock: a transaction already holds the whole store.
+func (t *TokenRepository) GetSessionForUpdate(ctx context.Context, id string) (*models.Session, error) {
+	return t.GetSession(ctx, id)
+}
+
+func (t *TokenRepository) GetSessionByRefreshTokenHash(ctx context.Context, hash string) (*models.Session, error) {
+	defer t.storage.lock()()
+
+	for _, session := range t.storage.data.sessions {
+		if session.RefreshTokenHash == hash {
+			return copySession(session), nil
+		}
+	}
+
+	return nil, storage.ErrRecordNotFound
+}
+
+func (t *TokenRepository) FindSessionsByUserID(ctx context.Context, userID string) ([]*models.Session, error) {
+	defer t.storage.lock()()
+
+	sessions := []*models.Session{}
+	for _, session := range t.storage.data.sessions {
+		if session.UserID == userID {
+			sessions = append(sessions, copySession(session))
+		}
+	}
+
+	slices.SortFunc(sessions, func(a, b *models.Session) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	return sessions, nil
+}
+
+func (t *TokenRepository) RevokeSession(ctx context.Context, id string) error {
+	defer t.storage.lock()()
+
+	if session, ok := t.storage.data.sessions[id]; ok {
+		session.IsRevoked = true
+		if session.RevokedAt == nil {
+			now := time.Now()
+			session.RevokedAt = &now
+		}
+	}
+
+	return nil
+}
+
+func (t *TokenRepository) DeleteSession(ctx context.Context, id string) error {
+	defer t.storage.lock()()
+
+	delete(t.storage.data.sessions, id)
+
+	return nil
+}
+
+func (t *TokenRepository) RotateSession(ctx context.Context, oldID string, session *models.Session) (*models.Session, error) {
+	defer t.storage.lock()()
+
+	if _, ok := t.storage.data.sessions[oldID]; !ok {
+		return nil, storage.ErrRecordNotFound
+	}
+
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = time.Now()
+	}
+
+	delete(t.storage.data.sessions, oldID)
+	t.storage.data.sessions[session.ID] = copySession(session)
+
+	return session, nil
+}
+
+func (t *TokenRepository) UpdateRefreshTokenHash(ctx context.Context, id string, hash string) error {
+	defer t.storage.lock()()
+
+	if session, ok := t.storage.data.sessions[id]; ok {
+		session.RefreshTokenHash = hash
+	}
+
+	return nil
+}
+
+func (t *TokenRepository) RecordAccessToken(ctx context.Context, id string, accessTokenID string, usedAt time.Time) error {
+	defer t.storage.lock()()
+
+	if session, ok := t.storage.data.sessions[id]; ok {
+		session.AccessTokenID = accessTokenID
+		session.LastUsedAt = usedAt
+	}
+
+	return nil
+}
+
+func (t *TokenRepository) DeleteExpiredSessions(ctx context.Context, now time.Time, revokedBefore time.Time, limit int) (int64, error) {
+	defer t.storage.lock()()
+
+	var n int64
+	for id, session := range t.storage.data.sessions {
+		if n >= int64(limit) {
+			break
+		}
+
+		revokedAt := session.CreatedAt
+		if session.RevokedAt != nil {
+			revokedAt = *session.RevokedAt
+		}
+
+		if session.ExpiresAt.Before(now) || (session.IsRevoked && revokedAt.Before(revokedBefore)) {
+			delete(t.storage.data.sessions, id)
+			n++
+		}
+	}
+
+	return n, nil
+}
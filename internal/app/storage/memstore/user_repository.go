@@ -0,0 +1,95 @@
+package memstore
+
+import (
+	"context"
+
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/models"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/storage"
+	"github.com/google/uuid"
+)
+
+type UserRepository struct {
+	storage *Storage
+}
+
+func (r *UserRepository) Create(ctx context.Context, u *models.User) error {
+	if err := u.Validate(); err != nil {
+		return err
+	}
+
+	if err := u.BeforeCreate(); err != nil {
+		return err
+	}
+
+	defer r.storage.lock()()
+
+	for _, other := range r.storage.data.users {
+		if other.Email == u.Email {
+			return storage.ErrAlreadyExists
+		}
+	}
+
+	u.ID = uuid.New().String()
+
+	stored := copyUser(u)
+	stored.Password = ""
+	r.storage.data.users[u.ID] = stored
+
+	return nil
+}
+
+func (r *UserRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	defer r.storage.lock()()
+
+	for _, u := range r.storage.data.users {
+		if u.Email == email {
+			return copyUser(u), nil
+		}
+	}
+
+	return nil, storage.ErrRecordNotFound
+}
+
+func (r *UserRepository) FindByID(ctx context.Context, id string) (*models.User, error) {
+	defer r.storage.lock()()
+
+	u, ok := r.storage.data.users[id]
+	if !ok {
+		return nil, storage.ErrRecordNotFound
+	}
+
+	return copyUser(u), nil
+}
+
+// FindByIDForUpdate needs no row lock: a transaction already holds the whole store.
+func (r *UserRepository) FindByIDForUpdate(ctx context.Context, id string) (*models.User, error) {
+	return r.FindByID(ctx, id)
+}
+
+func (r *UserRepository) UpdatePassword(ctx context.Context, u *models.User) error {
+	if err := u.BeforeCreate(); err != nil {
+		return err
+	}
+
+	defer r.storage.lock()()
+
+	if stored, ok := r.storage.data.users[u.ID]; ok {
+		stored.EncryptedPassword = u.EncryptedPassword
+		stored.PepperVersion = u.PepperVersion
+	}
+
+	return nil
+}
+
+func (r *UserRepository) SetDisabled(ctx context.Context, id string, disabled bool) error {
+	defer r.storage.lock()()
+
+	u, ok := r.storage.data.users[id]
+	if !ok {
+		return storage.ErrRecordNotFound
+	}
+
+	u.IsDisabled = disabled
+
+	return nil
+}
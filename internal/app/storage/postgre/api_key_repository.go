@@ -0,0 +1,145 @@
+package sqlstorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/models"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/storage"
+	"github.com/google/uuid"
+)
+
+type APIKeyRepository struct {
+	storage *Storage
+}
+
+func (r *APIKeyRepository) Create(ctx context.Context, k *models.APIKey) error {
+	if err := k.Validate(); err != nil {
+		return err
+	}
+
+	k.ID = uuid.New().String()
+
+	err := r.storage.db.QueryRowContext(
+		ctx,
+		"INSERT INTO api_keys (id, user_id, name, prefix, secret_hash, scopes, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING created_at",
+		k.ID,
+		k.UserID,
+		k.Name,
+		k.Prefix,
+		k.SecretHash,
+		strings.Join(k.Scopes, " "),
+		k.ExpiresAt,
+	).Scan(&k.CreatedAt)
+
+	return mapError(err)
+}
+
+func (r *APIKeyRepository) FindByPrefix(ctx context.Context, prefix string) (*models.APIKey, error) {
+	k, err := scanAPIKey(r.storage.db.QueryRowContext(
+		ctx,
+		"SELECT id, user_id, name, prefix, secret_hash, scopes, expires_at, last_used_at, created_at FROM api_keys WHERE prefix = $1",
+		prefix,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrRecordNotFound
+		}
+
+		return nil, err
+	}
+
+	return k, nil
+}
+
+func (r *APIKeyRepository) ListByUser(ctx context.Context, userID string) ([]*models.APIKey, error) {
+	rows, err := r.storage.db.QueryContext(
+		ctx,
+		"SELECT id, user_id, name, prefix, secret_hash, scopes, expires_at, last_used_at, created_at FROM api_keys WHERE user_id = $1 ORDER BY created_at",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*models.APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+
+	return keys, rows.Err()
+}
+
+func (r *APIKeyRepository) Delete(ctx context.Context, id string, userID string) error {
+	res, err := r.storage.db.ExecContext(
+		ctx,
+		"DELETE FROM api_keys WHERE id = $1 AND user_id = $2",
+		id,
+		userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return storage.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (r *APIKeyRepository) UpdateLastUsed(ctx context.Context, id string, t time.Time) error {
+	_, err := r.storage.db.ExecContext(
+		ctx,
+		"UPDATE api_keys SET last_used_at = $1 WHERE id = $2",
+		t,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAPIKey(row rowScanner) (*models.APIKey, error) {
+	var (
+		k      = &models.APIKey{}
+		scopes string
+	)
+
+	if err := row.Scan(
+		&k.ID,
+		&k.UserID,
+		&k.Name,
+		&k.Prefix,
+		&k.SecretHash,
+		&scopes,
+		&k.ExpiresAt,
+		&k.LastUsedAt,
+		&k.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	k.Scopes = strings.Fields(scopes)
+
+	return k, nil
+}
@@ -0,0 +1,53 @@
+package sqlstorage
+
+import (
+	"context"
+	"time"
+)
+
+type DenylistRepository struct {
+	storage *Storage
+}
+
+func (r *DenylistRepository) Add(ctx context.Context, jti string, expiresAt time.Time) error {
+	_, err := r.storage.db.ExecContext(
+		ctx,
+		"INSERT INTO token_denylist (jti, expires_at) VALUES ($1, $2) ON CONFLICT (jti) DO NOTHING",
+		jti,
+		expiresAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *DenylistRepository) Contains(ctx context.Context, jti string) (bool, error) {
+	var denied bool
+
+	err := r.storage.db.QueryRowContext(
+		ctx,
+		"SELECT EXISTS (SELECT 1 FROM token_denylist WHERE jti = $1 AND expires_at > now())",
+		jti,
+	).Scan(&denied)
+	if err != nil {
+		return false, err
+	}
+
+	return denied, nil
+}
+
+func (r *DenylistRepository) DeleteExpired(ctx context.Context, now time.Time, limit int) (int64, error) {
+	res, err := r.storage.db.ExecContext(
+		ctx,
+		"DELETE FROM token_denylist WHERE jti IN (SELECT jti FROM token_denylist WHERE expires_at < $1 LIMIT $2)",
+		now,
+		limit,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
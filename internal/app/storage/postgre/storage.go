@@ -1,22 +1,61 @@
 package sqlstorage
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 
 	"github.com/andreyxaxa/rest_auth_svc/internal/app/storage"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
+// uniqueViolation is the SQLSTATE of a unique constraint failure.
+const uniqueViolation = "23505"
+
+// dbtx is what repositories run queries on: the pool, or the transaction
+// of a storage returned to WithTx.
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
 type Storage struct {
-	db              *sql.DB
-	userRepository  storage.UserRepository
-	tokenRepository storage.TokenRepository
+	pool               *sql.DB
+	db                 dbtx
+	inTx               bool
+	userRepository     storage.UserRepository
+	tokenRepository    storage.TokenRepository
+	apiKeyRepository   storage.APIKeyRepository
+	denylistRepository storage.DenylistRepository
 }
 
 func New(db *sql.DB) *Storage {
 	return &Storage{
-		db: db,
+		pool: db,
+		db:   db,
+	}
+}
+
+// WithTx runs fn with a storage whose repositories share one transaction, and
+// commits it if fn succeeds. Called on such a storage, it joins the running
+// transaction instead.
+func (s *Storage) WithTx(ctx context.Context, fn func(storage.Storage) error) error {
+	if s.inTx {
+		return fn(s)
+	}
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(&Storage{pool: s.pool, db: tx, inTx: true}); err != nil {
+		tx.Rollback()
+		return err
 	}
+
+	return tx.Commit()
 }
 
 func (s *Storage) User() storage.UserRepository {
@@ -42,3 +81,65 @@ func (s *Storage) Token() storage.TokenRepository {
 
 	return s.tokenRepository
 }
+
+func (s *Storage) APIKey() storage.APIKeyRepository {
+	if s.apiKeyRepository != nil {
+		return s.apiKeyRepository
+	}
+
+	s.apiKeyRepository = &APIKeyRepository{
+		storage: s,
+	}
+
+	return s.apiKeyRepository
+}
+
+func (s *Storage) Denylist() storage.DenylistRepository {
+	if s.denylistRepository != nil {
+		return s.denylistRepository
+	}
+
+	s.denylistRepository = &DenylistRepository{
+		storage: s,
+	}
+
+	return s.denylistRepository
+}
+
+// mapError turns a unique constraint failure into storage.ErrAlreadyExists.
+func mapError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return storage.ErrAlreadyExists
+	}
+
+	return err
+}
+
+// TryLock takes the session-level Postgres advisory lock key if it is free.
+// The lock lives on a dedicated connection that unlock releases.
+func (s *Storage) TryLock(ctx context.Context, key int64) (func(), bool, error) {
+	conn, err := s.pool.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var ok bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&ok); err != nil {
+		conn.Close()
+		return nil, false, err
+	}
+
+	if !ok {
+		conn.Close()
+		return nil, false, nil
+	}
+
+	unlock := func() {
+		// released even when ctx is done, or the lock would outlive the run
+		conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key)
+		conn.Close()
+	}
+
+	return unlock, true, nil
+}
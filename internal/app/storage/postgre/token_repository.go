@@ -1,18 +1,34 @@
 package sqlstorage
 
-import "github.com/andreyxaxa/rest_auth_svc/internal/app/models"
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/models"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/storage"
+)
 
 type TokenRepository struct {
 	storage *Storage
 }
 
-func (t *TokenRepository) CreateSession(session *models.Session) (*models.Session, error) {
-	_, err := t.storage.db.Exec(
-		"INSERT INTO sessions (id, user_email, refresh_token_hash, is_revoked, expires_at) VALUES ($1, $2, $3, $4, $5)",
+func (t *TokenRepository) CreateSession(ctx context.Context, session *models.Session) (*models.Session, error) {
+	_, err := t.storage.db.ExecContext(
+		ctx,
+		"INSERT INTO sessions (id, user_id, audience, scope, dpop_jkt, access_token_id, refresh_token_hash, is_revoked, remember_me, authenticated_at, last_used_at, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)",
 		session.ID,
-		session.UserEmail,
+		session.UserID,
+		session.Audience,
+		session.Scope,
+		session.DPoPJKT,
+		session.AccessTokenID,
 		session.RefreshTokenHash,
 		session.IsRevoked,
+		session.RememberMe,
+		session.AuthenticatedAt,
+		session.LastUsedAt,
 		session.ExpiresAt,
 	)
 	if err != nil {
@@ -22,20 +38,83 @@ func (t *TokenRepository) CreateSession(session *models.Session) (*models.Sessio
 	return session, nil
 }
 
-func (t *TokenRepository) GetSession(id string) (*models.Session, error) {
-	session := &models.Session{}
+const sessionColumns = "id, user_id, audience, scope, dpop_jkt, access_token_id, refresh_token_hash, is_revoked, revoked_at, remember_me, authenticated_at, last_used_at, created_at, expires_at"
+
+func (t *TokenRepository) GetSession(ctx context.Context, id string) (*models.Session, error) {
+	return t.getSession(ctx, "SELECT "+sessionColumns+" FROM sessions WHERE id = $1", id)
+}
+
+func (t *TokenRepository) GetSessionForUpdate(ctx context.Context, id string) (*models.Session, error) {
+	return t.getSession(ctx, "SELECT "+sessionColumns+" FROM sessions WHERE id = $1 FOR UPDATE", id)
+}
+
+func (t *TokenRepository) GetSessionByRefreshTokenHash(ctx context.Context, hash string) (*models.Session, error) {
+	return t.getSession(ctx, "SELECT "+sessionColumns+" FROM sessions WHERE refresh_token_hash = $1", hash)
+}
 
-	err := t.storage.db.QueryRow(
-		"SELECT id, user_email, refresh_token_hash, is_revoked, created_at, expires_at FROM sessions WHERE id = $1",
+func (t *TokenRepository) FindSessionsByUserID(ctx context.Context, userID string) ([]*models.Session, error) {
+	rows, err := t.storage.db.QueryContext(
+		ctx,
+		"SELECT "+sessionColumns+" FROM sessions WHERE user_id = $1 ORDER BY created_at",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*models.Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+func (t *TokenRepository) RevokeSession(ctx context.Context, id string) error {
+	_, err := t.storage.db.ExecContext(
+		ctx,
+		"UPDATE sessions SET is_revoked = true, revoked_at = COALESCE(revoked_at, now()) WHERE id = $1",
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (t *TokenRepository) DeleteSession(ctx context.Context, id string) error {
+	_, err := t.storage.db.ExecContext(
+		ctx,
+		"DELETE FROM sessions WHERE id = $1",
 		id,
-	).Scan(
-		&session.ID,
-		&session.UserEmail,
-		&session.RefreshTokenHash,
-		&session.IsRevoked,
-		&session.CreatedAt,
-		&session.ExpiresAt,
 	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (t *TokenRepository) RotateSession(ctx context.Context, oldID string, session *models.Session) (*models.Session, error) {
+	err := t.storage.WithTx(ctx, func(tx storage.Storage) error {
+		if _, err := tx.Token().GetSessionForUpdate(ctx, oldID); err != nil {
+			return err
+		}
+
+		if err := tx.Token().DeleteSession(ctx, oldID); err != nil {
+			return err
+		}
+
+		_, err := tx.Token().CreateSession(ctx, session)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -43,9 +122,11 @@ func (t *TokenRepository) GetSession(id string) (*models.Session, error) {
 	return session, nil
 }
 
-func (t *TokenRepository) RevokeSession(id string) error {
-	_, err := t.storage.db.Exec(
-		"UPDATE sessions SET is_revoked = 1 WHERE id = $1",
+func (t *TokenRepository) UpdateRefreshTokenHash(ctx context.Context, id string, hash string) error {
+	_, err := t.storage.db.ExecContext(
+		ctx,
+		"UPDATE sessions SET refresh_token_hash = $1 WHERE id = $2",
+		hash,
 		id,
 	)
 	if err != nil {
@@ -55,9 +136,12 @@ func (t *TokenRepository) RevokeSession(id string) error {
 	return nil
 }
 
-func (t *TokenRepository) DeleteSession(id string) error {
-	_, err := t.storage.db.Exec(
-		"DELETE FROM sessions WHERE id = $1",
+func (t *TokenRepository) RecordAccessToken(ctx context.Context, id string, accessTokenID string, usedAt time.Time) error {
+	_, err := t.storage.db.ExecContext(
+		ctx,
+		"UPDATE sessions SET access_token_id = $1, last_used_at = $2 WHERE id = $3",
+		accessTokenID,
+		usedAt,
 		id,
 	)
 	if err != nil {
@@ -66,3 +150,61 @@ func (t *TokenRepository) DeleteSession(id string) error {
 
 	return nil
 }
+
+func (t *TokenRepository) DeleteExpiredSessions(ctx context.Context, now time.Time, revokedBefore time.Time, limit int) (int64, error) {
+	res, err := t.storage.db.ExecContext(
+		ctx,
+		`DELETE FROM sessions WHERE id IN (
+			SELECT id FROM sessions
+			WHERE expires_at < $1 OR (is_revoked AND COALESCE(revoked_at, created_at) < $2)
+			LIMIT $3
+		)`,
+		now,
+		revokedBefore,
+		limit,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+func (t *TokenRepository) getSession(ctx context.Context, query string, arg string) (*models.Session, error) {
+	session, err := scanSession(t.storage.db.QueryRowContext(ctx, query, arg))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrRecordNotFound
+		}
+
+		return nil, err
+	}
+
+	return session, nil
+}
+
+func scanSession(row rowScanner) (*models.Session, error) {
+	session := &models.Session{}
+
+	err := row.Scan(
+		&session.ID,
+		&session.UserID,
+		&session.Audience,
+		&session.Scope,
+		&session.DPoPJKT,
+		&session.AccessTokenID,
+		&session.RefreshTokenHash,
+		&session.IsRevoked,
+		&session.RevokedAt,
+		&session.RememberMe,
+		&session.AuthenticatedAt,
+		&session.LastUsedAt,
+		&session.CreatedAt,
+		&session.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
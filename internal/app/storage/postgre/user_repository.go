@@ -1,8 +1,10 @@
 package sqlstorage
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"strings"
 
 	"github.com/andreyxaxa/rest_auth_svc/internal/app/models"
 	"github.com/andreyxaxa/rest_auth_svc/internal/app/storage"
@@ -13,7 +15,7 @@ type UserRepository struct {
 	storage *Storage
 }
 
-func (r *UserRepository) Create(u *models.User) error {
+func (r *UserRepository) Create(ctx context.Context, u *models.User) error {
 	if err := u.Validate(); err != nil {
 		return err
 	}
@@ -24,28 +26,37 @@ func (r *UserRepository) Create(u *models.User) error {
 
 	u.ID = uuid.New().String()
 
-	_, err := r.storage.db.Exec(
-		"INSERT INTO users (id, email, encrypted_password) VALUES ($1, $2, $3)",
+	_, err := r.storage.db.ExecContext(
+		ctx,
+		"INSERT INTO users (id, email, encrypted_password, pepper_version, scopes) VALUES ($1, $2, $3, $4, $5)",
 		u.ID,
 		u.Email,
 		u.EncryptedPassword,
+		u.PepperVersion,
+		strings.Join(u.Scopes, " "),
 	)
 	if err != nil {
-		return err
+		return mapError(err)
 	}
 
 	return nil
 }
 
-func (r *UserRepository) FindByEmail(email string) (*models.User, error) {
+func (r *UserRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	var scopes string
+
 	u := &models.User{}
-	if err := r.storage.db.QueryRow(
-		"SELECT id, email, encrypted_password FROM users WHERE email = $1",
+	if err := r.storage.db.QueryRowContext(
+		ctx,
+		"SELECT id, email, encrypted_password, pepper_version, scopes, is_disabled FROM users WHERE email = $1",
 		email,
 	).Scan(
 		&u.ID,
 		&u.Email,
 		&u.EncryptedPassword,
+		&u.PepperVersion,
+		&scopes,
+		&u.IsDisabled,
 	); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, storage.ErrRecordNotFound
@@ -54,18 +65,34 @@ func (r *UserRepository) FindByEmail(email string) (*models.User, error) {
 		return nil, err
 	}
 
+	u.Scopes = strings.Fields(scopes)
+
 	return u, nil
 }
 
-func (r *UserRepository) FindByID(id string) (*models.User, error) {
+func (r *UserRepository) FindByID(ctx context.Context, id string) (*models.User, error) {
+	return r.findByID(ctx, "SELECT id, email, encrypted_password, pepper_version, scopes, is_disabled FROM users WHERE id = $1", id)
+}
+
+func (r *UserRepository) FindByIDForUpdate(ctx context.Context, id string) (*models.User, error) {
+	return r.findByID(ctx, "SELECT id, email, encrypted_password, pepper_version, scopes, is_disabled FROM users WHERE id = $1 FOR UPDATE", id)
+}
+
+func (r *UserRepository) findByID(ctx context.Context, query string, id string) (*models.User, error) {
+	var scopes string
+
 	u := &models.User{}
-	if err := r.storage.db.QueryRow(
-		"SELECT id, email, encrypted_password FROM users WHERE id = $1",
+	if err := r.storage.db.QueryRowContext(
+		ctx,
+		query,
 		id,
 	).Scan(
 		&u.ID,
 		&u.Email,
 		&u.EncryptedPassword,
+		&u.PepperVersion,
+		&scopes,
+		&u.IsDisabled,
 	); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, storage.ErrRecordNotFound
@@ -74,5 +101,49 @@ func (r *UserRepository) FindByID(id string) (*models.User, error) {
 		return nil, err
 	}
 
+	u.Scopes = strings.Fields(scopes)
+
 	return u, nil
 }
+
+func (r *UserRepository) UpdatePassword(ctx context.Context, u *models.User) error {
+	if err := u.BeforeCreate(); err != nil {
+		return err
+	}
+
+	_, err := r.storage.db.ExecContext(
+		ctx,
+		"UPDATE users SET encrypted_password = $1, pepper_version = $2 WHERE id = $3",
+		u.EncryptedPassword,
+		u.PepperVersion,
+		u.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *UserRepository) SetDisabled(ctx context.Context, id string, disabled bool) error {
+	res, err := r.storage.db.ExecContext(
+		ctx,
+		"UPDATE users SET is_disabled = $1 WHERE id = $2",
+		disabled,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return storage.ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package redisstore
+
+import (
+	"context"
+
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/storage"
+)
+
+var _ storage.Storage = (*Storage)(nil)
+
+// Storage serves sessions from Redis and everything else from another
+// storage. Session writes take effect at once: they are not part of the
+// transactions WithTx runs on the other storage. Sessions claimed in such a
+// transaction are released when it ends, whatever its outcome, like the row
+// locks of a database.
+type Storage struct {
+	storage.Storage
+	tokens *TokenRepository
+}
+
+func Wrap(base storage.Storage, tokens *TokenRepository) *Storage {
+	return &Storage{
+		Storage: base,
+		tokens:  tokens,
+	}
+}
+
+func (s *Storage) Token() storage.TokenRepository {
+	return s.tokens
+}
+
+func (s *Storage) WithTx(ctx context.Context, fn func(storage.Storage) error) error {
+	// a nested call joins the transaction and leaves its claims to it
+	tokens := s.tokens
+	if tokens.claimed == nil {
+		tokens = tokens.inTx()
+	}
+
+	err := s.Storage.WithTx(ctx, func(tx storage.Storage) error {
+		return fn(&Storage{Storage: tx, tokens: tokens})
+	})
+
+	if tokens != s.tokens {
+		// a claim left behind still runs out after claimTTL, so a failed
+		// release must not fail a transaction already committed
+		_ = tokens.releaseClaims(ctx)
+	}
+
+	return err
+}
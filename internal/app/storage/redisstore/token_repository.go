@@ -0,0 +1,477 @@
+package redisstore
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/models"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/storage"
+	"github.com/redis/go-redis/v9"
+)
+
+// Keys. A session is a hash that expires at its ExpiresAt, indexed by
+// refresh token hash and by user; the user index is a sorted set scored by
+// creation time that lives as long as the user's longest session.
+const (
+	sessionKeyPrefix        = "auth:session:"
+	sessionRefreshKeyPrefix = "auth:session_refresh:"
+	sessionClaimKeyPrefix   = "auth:session_claim:"
+	userSessionsKeyPrefix   = "auth:user_sessions:"
+	revokedSessionsKey      = "auth:sessions_revoked"
+
+	// claimTTL bounds how long a session claimed by GetSessionForUpdate stays
+	// claimed if the claimer never deletes it.
+	claimTTL = 10 * time.Second
+)
+
+var (
+	// KEYS: session, refresh index, user index
+	// ARGV: id, expires at (unix ms), created at (unix ms), field value...
+	createSessionScript = redis.NewScript(`
+redis.call('HSET', KEYS[1], unpack(ARGV, 4))
+redis.call('PEXPIREAT', KEYS[1], ARGV[2])
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl <= 0 then
+	return 0
+end
+redis.call('SET', KEYS[2], ARGV[1], 'PX', ttl)
+redis.call('ZADD', KEYS[3], ARGV[3], ARGV[1])
+local indexTTL = redis.call('PTTL', KEYS[3])
+if indexTTL == -1 or indexTTL < ttl then
+	redis.call('PEXPIRE', KEYS[3], ttl)
+end
+return 1`)
+
+	// KEYS: session
+	// ARGV: field value...
+	updateSessionScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+redis.call('HSET', KEYS[1], unpack(ARGV))
+return 1`)
+
+	// KEYS: session, revoked index
+	// ARGV: id, revoked at, revoked at (unix ms)
+	revokeSessionScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'is_revoked', '1')
+if redis.call('HSETNX', KEYS[1], 'revoked_at', ARGV[2]) == 1 then
+	redis.call('ZADD', KEYS[2], ARGV[3], ARGV[1])
+end
+return 1`)
+
+	// KEYS: session
+	// ARGV: id, new hash, refresh index prefix
+	updateRefreshTokenHashScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+local old = redis.call('HGET', KEYS[1], 'refresh_token_hash')
+if old then
+	redis.call('DEL', ARGV[3] .. old)
+end
+redis.call('HSET', KEYS[1], 'refresh_token_hash', ARGV[2])
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl > 0 then
+	redis.call('SET', ARGV[3] .. ARGV[2], ARGV[1], 'PX', ttl)
+end
+return 1`)
+
+	// KEYS: old session, old claim, revoked index, session, refresh index, user index
+	// ARGV: old id, refresh index prefix, user index prefix, id, expires at (unix ms), created at (unix ms), field value...
+	rotateSessionScript = redis.NewScript(`
+local old = redis.call('HMGET', KEYS[1], 'user_id', 'refresh_token_hash')
+if not old[1] then
+	return 0
+end
+redis.call('DEL', KEYS[1], KEYS[2])
+redis.call('ZREM', KEYS[3], ARGV[1])
+if old[2] then
+	redis.call('DEL', ARGV[2] .. old[2])
+end
+redis.call('ZREM', ARGV[3] .. old[1], ARGV[1])
+redis.call('HSET', KEYS[4], unpack(ARGV, 7))
+redis.call('PEXPIREAT', KEYS[4], ARGV[5])
+local ttl = redis.call('PTTL', KEYS[4])
+if ttl <= 0 then
+	return 1
+end
+redis.call('SET', KEYS[5], ARGV[4], 'PX', ttl)
+redis.call('ZADD', KEYS[6], ARGV[6], ARGV[4])
+local indexTTL = redis.call('PTTL', KEYS[6])
+if indexTTL == -1 or indexTTL < ttl then
+	redis.call('PEXPIRE', KEYS[6], ttl)
+end
+return 1`)
+
+	// KEYS: session, claim, revoked index
+	// ARGV: id, refresh index prefix, user index prefix
+	deleteSessionScript = redis.NewScript(`
+redis.call('DEL', KEYS[2])
+redis.call('ZREM', KEYS[3], ARGV[1])
+local fields = redis.call('HMGET', KEYS[1], 'user_id', 'refresh_token_hash')
+if not fields[1] then
+	return 0
+end
+redis.call('DEL', KEYS[1])
+redis.call('DEL', ARGV[2] .. fields[2])
+redis.call('ZREM', ARGV[3] .. fields[1], ARGV[1])
+return 1`)
+)
+
+// TokenRepository keeps sessions in Redis. Expired sessions are dropped by
+// Redis itself; DeleteExpiredSessions only purges revoked ones.
+//
+// It takes a single-node client only: the scripts reach index keys they
+// derive from session fields, which Redis Cluster does not allow as those
+// keys live in other slots.
+type TokenRepository struct {
+	client *redis.Client
+	// claimed holds the sessions claimed in a transaction, released when it
+	// ends; nil outside one.
+	claimed *[]string
+}
+
+func NewTokenRepository(client *redis.Client) *TokenRepository {
+	return &TokenRepository{
+		client: client,
+	}
+}
+
+// CreateSession stores session until its ExpiresAt; one that has already
+// expired is not stored at all.
+func (t *TokenRepository) CreateSession(ctx context.Context, session *models.Session) (*models.Session, error) {
+	createdAt := session.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+
+	args := []any{session.ID, session.ExpiresAt.UnixMilli(), createdAt.UnixMilli()}
+	args = append(args, sessionFields(session, createdAt)...)
+
+	err := createSessionScript.Run(
+		ctx,
+		t.client,
+		[]string{sessionKeyPrefix + session.ID, sessionRefreshKeyPrefix + session.RefreshTokenHash, userSessionsKeyPrefix + session.UserID},
+		args...,
+	).Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+func (t *TokenRepository) GetSession(ctx context.Context, id string) (*models.Session, error) {
+	fields, err := t.client.HGetAll(ctx, sessionKeyPrefix+id).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	return parseSession(fields)
+}
+
+// GetSessionForUpdate claims the session until the transaction it runs in
+// ends, see Storage.WithTx, or it is deleted; for claimTTL at most. There
+// are no transactions in Redis to lock it in: a concurrent claim instead
+// finds nothing, as if the first claimer had already replaced it.
+func (t *TokenRepository) GetSessionForUpdate(ctx context.Context, id string) (*models.Session, error) {
+	ok, err := t.client.SetNX(ctx, sessionClaimKeyPrefix+id, 1, claimTTL).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, storage.ErrRecordNotFound
+	}
+
+	if t.claimed != nil {
+		*t.claimed = append(*t.claimed, id)
+	}
+
+	return t.GetSession(ctx, id)
+}
+
+// inTx returns the repository for one transaction, which keeps track of its claims.
+func (t *TokenRepository) inTx() *TokenRepository {
+	return &TokenRepository{
+		client:  t.client,
+		claimed: &[]string{},
+	}
+}
+
+// releaseClaims drops the claims made in the transaction t was returned for.
+func (t *TokenRepository) releaseClaims(ctx context.Context) error {
+	if len(*t.claimed) == 0 {
+		return nil
+	}
+
+	keys := make([]string, len(*t.claimed))
+	for i, id := range *t.claimed {
+		keys[i] = sessionClaimKeyPrefix + id
+	}
+
+	// released even when the request that ran the transaction is cancelled
+	return t.client.Del(context.WithoutCancel(ctx), keys...).Err()
+}
+
+func (t *TokenRepository) GetSessionByRefreshTokenHash(ctx context.Context, hash string) (*models.Session, error) {
+	id, err := t.client.Get(ctx, sessionRefreshKeyPrefix+hash).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, storage.ErrRecordNotFound
+		}
+
+		return nil, err
+	}
+
+	return t.GetSession(ctx, id)
+}
+
+func (t *TokenRepository) FindSessionsByUserID(ctx context.Context, userID string) ([]*models.Session, error) {
+	key := userSessionsKeyPrefix + userID
+
+	ids, err := t.client.ZRange(ctx, key, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	cmds := make([]*redis.MapStringStringCmd, len(ids))
+	_, err = t.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for i, id := range ids {
+			cmds[i] = p.HGetAll(ctx, sessionKeyPrefix+id)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := []*models.Session{}
+	gone := []any{}
+	for i, cmd := range cmds {
+		session, err := parseSession(cmd.Val())
+		if err != nil {
+			if errors.Is(err, storage.ErrRecordNotFound) {
+				gone = append(gone, ids[i])
+				continue
+			}
+
+			return nil, err
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	// expired sessions leave their ids behind
+	if len(gone) > 0 {
+		if err := t.client.ZRem(ctx, key, gone...).Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return sessions, nil
+}
+
+func (t *TokenRepository) RevokeSession(ctx context.Context, id string) error {
+	now := time.Now()
+
+	return revokeSessionScript.Run(
+		ctx,
+		t.client,
+		[]string{sessionKeyPrefix + id, revokedSessionsKey},
+		id,
+		formatTime(now),
+		now.UnixMilli(),
+	).Err()
+}
+
+func (t *TokenRepository) DeleteSession(ctx context.Context, id string) error {
+	_, err := t.deleteSession(ctx, id)
+	return err
+}
+
+// RotateSession replaces oldID with session in one script.
+func (t *TokenRepository) RotateSession(ctx context.Context, oldID string, session *models.Session) (*models.Session, error) {
+	createdAt := session.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+
+	args := []any{oldID, sessionRefreshKeyPrefix, userSessionsKeyPrefix, session.ID, session.ExpiresAt.UnixMilli(), createdAt.UnixMilli()}
+	args = append(args, sessionFields(session, createdAt)...)
+
+	rotated, err := rotateSessionScript.Run(
+		ctx,
+		t.client,
+		[]string{
+			sessionKeyPrefix + oldID, sessionClaimKeyPrefix + oldID, revokedSessionsKey,
+			sessionKeyPrefix + session.ID, sessionRefreshKeyPrefix + session.RefreshTokenHash, userSessionsKeyPrefix + session.UserID,
+		},
+		args...,
+	).Int()
+	if err != nil {
+		return nil, err
+	}
+
+	if rotated == 0 {
+		return nil, storage.ErrRecordNotFound
+	}
+
+	return session, nil
+}
+
+func (t *TokenRepository) UpdateRefreshTokenHash(ctx context.Context, id string, hash string) error {
+	return updateRefreshTokenHashScript.Run(
+		ctx,
+		t.client,
+		[]string{sessionKeyPrefix + id},
+		id,
+		hash,
+		sessionRefreshKeyPrefix,
+	).Err()
+}
+
+func (t *TokenRepository) RecordAccessToken(ctx context.Context, id string, accessTokenID string, usedAt time.Time) error {
+	return updateSessionScript.Run(
+		ctx,
+		t.client,
+		[]string{sessionKeyPrefix + id},
+		"access_token_id", accessTokenID,
+		"last_used_at", formatTime(usedAt),
+	).Err()
+}
+
+// DeleteExpiredSessions deletes sessions revoked before revokedBefore. Those
+// that expired are already gone, so now is not needed.
+func (t *TokenRepository) DeleteExpiredSessions(ctx context.Context, now time.Time, revokedBefore time.Time, limit int) (int64, error) {
+	ids, err := t.client.ZRangeArgs(ctx, redis.ZRangeArgs{
+		Key:     revokedSessionsKey,
+		Start:   "-inf",
+		Stop:    "(" + strconv.FormatInt(revokedBefore.UnixMilli(), 10),
+		ByScore: true,
+		Count:   int64(limit),
+	}).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	var n int64
+	for _, id := range ids {
+		deleted, err := t.deleteSession(ctx, id)
+		if err != nil {
+			return n, err
+		}
+
+		if deleted {
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+func (t *TokenRepository) deleteSession(ctx context.Context, id string) (bool, error) {
+	deleted, err := deleteSessionScript.Run(
+		ctx,
+		t.client,
+		[]string{sessionKeyPrefix + id, sessionClaimKeyPrefix + id, revokedSessionsKey},
+		id,
+		sessionRefreshKeyPrefix,
+		userSessionsKeyPrefix,
+	).Int()
+	if err != nil {
+		return false, err
+	}
+
+	return deleted == 1, nil
+}
+
+func sessionFields(session *models.Session, createdAt time.Time) []any {
+	fields := []any{
+		"id", session.ID,
+		"user_id", session.UserID,
+		"audience", session.Audience,
+		"scope", session.Scope,
+		"dpop_jkt", session.DPoPJKT,
+		"access_token_id", session.AccessTokenID,
+		"refresh_token_hash", session.RefreshTokenHash,
+		"is_revoked", formatBool(session.IsRevoked),
+		"remember_me", formatBool(session.RememberMe),
+		"authenticated_at", formatTime(session.AuthenticatedAt),
+		"last_used_at", formatTime(session.LastUsedAt),
+		"created_at", formatTime(createdAt),
+		"expires_at", formatTime(session.ExpiresAt),
+	}
+
+	if session.RevokedAt != nil {
+		fields = append(fields, "revoked_at", formatTime(*session.RevokedAt))
+	}
+
+	return fields
+}
+
+func parseSession(fields map[string]string) (*models.Session, error) {
+	if len(fields) == 0 {
+		return nil, storage.ErrRecordNotFound
+	}
+
+	session := &models.Session{
+		ID:               fields["id"],
+		UserID:           fields["user_id"],
+		Audience:         fields["audience"],
+		Scope:            fields["scope"],
+		DPoPJKT:          fields["dpop_jkt"],
+		AccessTokenID:    fields["access_token_id"],
+		RefreshTokenHash: fields["refresh_token_hash"],
+		IsRevoked:        fields["is_revoked"] == "1",
+		RememberMe:       fields["remember_me"] == "1",
+	}
+
+	times := []struct {
+		field string
+		dst   *time.Time
+	}{
+		{"authenticated_at", &session.AuthenticatedAt},
+		{"last_used_at", &session.LastUsedAt},
+		{"created_at", &session.CreatedAt},
+		{"expires_at", &session.ExpiresAt},
+	}
+	for _, tm := range times {
+		v, err := time.Parse(time.RFC3339Nano, fields[tm.field])
+		if err != nil {
+			return nil, err
+		}
+
+		*tm.dst = v
+	}
+
+	if v, ok := fields["revoked_at"]; ok {
+		revokedAt, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return nil, err
+		}
+
+		session.RevokedAt = &revokedAt
+	}
+
+	return session, nil
+}
+
+func formatTime(t time.Time) string {
+	return t.Format(time.RFC3339Nano)
+}
+
+func formatBool(b bool) string {
+	if b {
+		return "1"
+	}
+
+	return "0"
+}
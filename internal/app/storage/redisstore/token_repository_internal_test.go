@@ -0,0 +1,227 @@
+package redisstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/models"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/storage"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/storage/memstore"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/storage/storagetest"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRepository(t *testing.T) (*TokenRepository, *miniredis.Miniredis) {
+	t.Helper()
+
+	m := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: m.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return NewTokenRepository(client), m
+}
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		r, _ := newTestRepository(t)
+		return Wrap(memstore.New(), r)
+	})
+}
+
+func newTestSession(id string, ttl time.Duration) *models.Session {
+	now := time.Now()
+
+	return &models.Session{
+		ID:               id,
+		UserID:           "u",
+		Scope:            "profile",
+		RefreshTokenHash: "hash-" + id,
+		AuthenticatedAt:  now,
+		LastUsedAt:       now,
+		ExpiresAt:        now.Add(ttl),
+	}
+}
+
+func TestTokenRepository_Session(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestRepository(t)
+
+	session := newTestSession("a", time.Hour)
+	_, err := r.CreateSession(ctx, session)
+	require.NoError(t, err)
+
+	stored, err := r.GetSession(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, session.UserID, stored.UserID)
+	assert.Equal(t, session.Scope, stored.Scope)
+	assert.True(t, session.ExpiresAt.Equal(stored.ExpiresAt))
+	assert.False(t, stored.IsRevoked)
+	assert.Nil(t, stored.RevokedAt)
+
+	stored, err = r.GetSessionByRefreshTokenHash(ctx, "hash-a")
+	require.NoError(t, err)
+	assert.Equal(t, "a", stored.ID)
+
+	require.NoError(t, r.UpdateRefreshTokenHash(ctx, "a", "rotated"))
+	_, err = r.GetSessionByRefreshTokenHash(ctx, "hash-a")
+	assert.ErrorIs(t, err, storage.ErrRecordNotFound)
+	_, err = r.GetSessionByRefreshTokenHash(ctx, "rotated")
+	assert.NoError(t, err)
+
+	require.NoError(t, r.RevokeSession(ctx, "a"))
+	stored, err = r.GetSession(ctx, "a")
+	require.NoError(t, err)
+	assert.True(t, stored.IsRevoked)
+	assert.NotNil(t, stored.RevokedAt)
+
+	require.NoError(t, r.DeleteSession(ctx, "a"))
+	_, err = r.GetSession(ctx, "a")
+	assert.ErrorIs(t, err, storage.ErrRecordNotFound)
+	_, err = r.GetSessionByRefreshTokenHash(ctx, "rotated")
+	assert.ErrorIs(t, err, storage.ErrRecordNotFound)
+
+	// updates do not bring a missing session back
+	require.NoError(t, r.RecordAccessToken(ctx, "a", "jti", time.Now()))
+	require.NoError(t, r.RevokeSession(ctx, "a"))
+	_, err = r.GetSession(ctx, "a")
+	assert.ErrorIs(t, err, storage.ErrRecordNotFound)
+}
+
+func TestTokenRepository_Expiry(t *testing.T) {
+	ctx := context.Background()
+	r, m := newTestRepository(t)
+
+	for id, ttl := range map[string]time.Duration{"short": time.Minute, "long": time.Hour} {
+		_, err := r.CreateSession(ctx, newTestSession(id, ttl))
+		require.NoError(t, err)
+	}
+
+	_, err := r.CreateSession(ctx, newTestSession("expired", -time.Minute))
+	require.NoError(t, err)
+	_, err = r.GetSession(ctx, "expired")
+	assert.ErrorIs(t, err, storage.ErrRecordNotFound)
+
+	m.FastForward(2 * time.Minute)
+
+	_, err = r.GetSession(ctx, "short")
+	assert.ErrorIs(t, err, storage.ErrRecordNotFound)
+	_, err = r.GetSessionByRefreshTokenHash(ctx, "hash-short")
+	assert.ErrorIs(t, err, storage.ErrRecordNotFound)
+
+	sessions, err := r.FindSessionsByUserID(ctx, "u")
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, "long", sessions[0].ID)
+
+	// the user index outlives only the longest session
+	m.FastForward(time.Hour)
+	assert.False(t, m.Exists(userSessionsKeyPrefix+"u"))
+}
+
+func TestTokenRepository_FindSessionsByUserID(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestRepository(t)
+
+	for i, id := range []string{"a", "b", "c"} {
+		session := newTestSession(id, time.Hour)
+		session.CreatedAt = time.Now().Add(time.Duration(i) * time.Second)
+		_, err := r.CreateSession(ctx, session)
+		require.NoError(t, err)
+	}
+
+	other := newTestSession("d", time.Hour)
+	other.UserID = "v"
+	_, err := r.CreateSession(ctx, other)
+	require.NoError(t, err)
+
+	require.NoError(t, r.DeleteSession(ctx, "b"))
+
+	sessions, err := r.FindSessionsByUserID(ctx, "u")
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, "a", sessions[0].ID)
+	assert.Equal(t, "c", sessions[1].ID)
+}
+
+func TestTokenRepository_GetSessionForUpdate(t *testing.T) {
+	ctx := context.Background()
+	r, m := newTestRepository(t)
+
+	_, err := r.CreateSession(ctx, newTestSession("a", time.Hour))
+	require.NoError(t, err)
+
+	_, err = r.GetSessionForUpdate(ctx, "a")
+	require.NoError(t, err)
+
+	// a concurrent claim loses
+	_, err = r.GetSessionForUpdate(ctx, "a")
+	assert.ErrorIs(t, err, storage.ErrRecordNotFound)
+
+	// an abandoned claim runs out
+	m.FastForward(claimTTL)
+	_, err = r.GetSessionForUpdate(ctx, "a")
+	assert.NoError(t, err)
+}
+
+func TestStorage_WithTxReleasesClaims(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestRepository(t)
+	s := Wrap(memstore.New(), r)
+
+	_, err := r.CreateSession(ctx, newTestSession("a", time.Hour))
+	require.NoError(t, err)
+
+	errRollback := errors.New("rollback")
+	err = s.WithTx(ctx, func(tx storage.Storage) error {
+		if _, err := tx.Token().GetSessionForUpdate(ctx, "a"); err != nil {
+			return err
+		}
+
+		// a nested transaction leaves the claim to the outer one
+		require.NoError(t, tx.WithTx(ctx, func(storage.Storage) error { return nil }))
+		_, err := r.GetSessionForUpdate(ctx, "a")
+		assert.ErrorIs(t, err, storage.ErrRecordNotFound)
+
+		return errRollback
+	})
+	require.ErrorIs(t, err, errRollback)
+
+	// the failed transaction gave up its claim
+	_, err = r.GetSessionForUpdate(ctx, "a")
+	assert.NoError(t, err)
+}
+
+func TestTokenRepository_DeleteExpiredSessions(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestRepository(t)
+
+	for _, id := range []string{"a", "b", "c"} {
+		_, err := r.CreateSession(ctx, newTestSession(id, time.Hour))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, r.RevokeSession(ctx, "a"))
+	require.NoError(t, r.RevokeSession(ctx, "b"))
+
+	n, err := r.DeleteExpiredSessions(ctx, time.Now(), time.Now().Add(-time.Minute), 10)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	n, err = r.DeleteExpiredSessions(ctx, time.Now(), time.Now().Add(time.Minute), 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	n, err = r.DeleteExpiredSessions(ctx, time.Now(), time.Now().Add(time.Minute), 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	sessions, err := r.FindSessionsByUserID(ctx, "u")
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, "c", sessions[0].ID)
+}
@@ -0,0 +1,158 @@
+package sqlitestorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/storage"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// pragmas are added to every DSN: foreign keys are off by default in SQLite,
+// writers wait for each other instead of failing at once, and transactions
+// take the write lock when they begin, so that reading a row and then
+// updating it cannot interleave with another writer.
+const pragmas = "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_txlock=immediate&_time_format=sqlite"
+
+// dbtx is what repositories run queries on: the pool, or the transaction
+// of a storage returned to WithTx.
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Storage keeps everything in one SQLite file, for single-instance
+// deployments and tests. Timestamps are stored as UTC text.
+type Storage struct {
+	pool               *sql.DB
+	db                 dbtx
+	inTx               bool
+	lock               *sync.Mutex
+	userRepository     storage.UserRepository
+	tokenRepository    storage.TokenRepository
+	apiKeyRepository   storage.APIKeyRepository
+	denylistRepository storage.DenylistRepository
+}
+
+// Open opens the database file at path, which may carry driver parameters
+// of its own after a '?'.
+func Open(path string) (*sql.DB, error) {
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+
+	db, err := sql.Open("sqlite", path+sep+pragmas)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func New(db *sql.DB) *Storage {
+	return &Storage{
+		pool: db,
+		db:   db,
+		lock: &sync.Mutex{},
+	}
+}
+
+// WithTx runs fn with a storage whose repositories share one transaction, and
+// commits it if fn succeeds. Called on such a storage, it joins the running
+// transaction instead.
+func (s *Storage) WithTx(ctx context.Context, fn func(storage.Storage) error) error {
+	if s.inTx {
+		return fn(s)
+	}
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(&Storage{pool: s.pool, db: tx, inTx: true, lock: s.lock}); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *Storage) User() storage.UserRepository {
+	if s.userRepository != nil {
+		return s.userRepository
+	}
+
+	s.userRepository = &UserRepository{
+		storage: s,
+	}
+
+	return s.userRepository
+}
+
+func (s *Storage) Token() storage.TokenRepository {
+	if s.tokenRepository != nil {
+		return s.tokenRepository
+	}
+
+	s.tokenRepository = &TokenRepository{
+		storage: s,
+	}
+
+	return s.tokenRepository
+}
+
+func (s *Storage) APIKey() storage.APIKeyRepository {
+	if s.apiKeyRepository != nil {
+		return s.apiKeyRepository
+	}
+
+	s.apiKeyRepository = &APIKeyRepository{
+		storage: s,
+	}
+
+	return s.apiKeyRepository
+}
+
+func (s *Storage) Denylist() storage.DenylistRepository {
+	if s.denylistRepository != nil {
+		return s.denylistRepository
+	}
+
+	s.denylistRepository = &DenylistRepository{
+		storage: s,
+	}
+
+	return s.denylistRepository
+}
+
+// mapError turns a unique constraint failure into storage.ErrAlreadyExists.
+func mapError(err error) error {
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) && (sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY) {
+		return storage.ErrAlreadyExists
+	}
+
+	return err
+}
+
+// TryLock takes an in-process lock; the key is ignored. A SQLite file is
+// served by one instance, so there is no one else to coordinate with.
+func (s *Storage) TryLock(ctx context.Context, key int64) (func(), bool, error) {
+	if !s.lock.TryLock() {
+		return nil, false, nil
+	}
+
+	return s.lock.Unlock, true, nil
+}
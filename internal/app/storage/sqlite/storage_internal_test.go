@@ -0,0 +1,112 @@
+package sqlitestorage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/models"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/schema"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/storage"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/storage/storagetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestStorage migrates a fresh database file in a temp dir.
+func newTestStorage(t *testing.T) *Storage {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "auth.db")
+
+	m, err := schema.New("sqlite://" + path)
+	require.NoError(t, err)
+	require.NoError(t, m.Up())
+	require.NoError(t, m.Close())
+
+	db, err := Open(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	return New(db)
+}
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return newTestStorage(t)
+	})
+}
+
+func newTestUser(t *testing.T, s *Storage) *models.User {
+	t.Helper()
+
+	u := &models.User{Email: "user@example.org", Password: "password1"}
+	require.NoError(t, s.User().Create(context.Background(), u))
+
+	return u
+}
+
+func TestTokenRepository_Timestamps(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t)
+	u := newTestUser(t, s)
+
+	// stored as UTC whatever the zone they come in
+	now := time.Now().In(time.FixedZone("UTC+3", 3*60*60))
+	for i, expiresAt := range []time.Time{now.Add(-time.Second), now.Add(-time.Millisecond), now.Add(time.Hour)} {
+		_, err := s.Token().CreateSession(ctx, &models.Session{
+			ID:              string(rune('a' + i)),
+			UserID:          u.ID,
+			AuthenticatedAt: now,
+			LastUsedAt:      now,
+			ExpiresAt:       expiresAt,
+		})
+		require.NoError(t, err)
+	}
+
+	session, err := s.Token().GetSession(ctx, "c")
+	require.NoError(t, err)
+	assert.True(t, session.AuthenticatedAt.Equal(now))
+	assert.Nil(t, session.RevokedAt)
+
+	n, err := s.Token().DeleteExpiredSessions(ctx, now, now.Add(-time.Hour), 100)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	require.NoError(t, s.Token().RevokeSession(ctx, "c"))
+	session, err = s.Token().GetSession(ctx, "c")
+	require.NoError(t, err)
+	assert.True(t, session.IsRevoked)
+	assert.NotNil(t, session.RevokedAt)
+}
+
+func TestStorage_CascadesUserDeletion(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t)
+	u := newTestUser(t, s)
+
+	_, err := s.Token().CreateSession(ctx, &models.Session{ID: "a", UserID: u.ID})
+	require.NoError(t, err)
+
+	_, err = s.pool.ExecContext(ctx, "DELETE FROM users WHERE id = $1", u.ID)
+	require.NoError(t, err)
+
+	_, err = s.Token().GetSession(ctx, "a")
+	assert.ErrorIs(t, err, storage.ErrRecordNotFound)
+}
+
+func TestStorage_TryLock(t *testing.T) {
+	s := newTestStorage(t)
+
+	unlock, ok, err := s.TryLock(context.Background(), 1)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	_, ok, _ = s.TryLock(context.Background(), 1)
+	assert.False(t, ok)
+
+	unlock()
+	_, ok, _ = s.TryLock(context.Background(), 1)
+	assert.True(t, ok)
+}
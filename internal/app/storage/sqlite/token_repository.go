@@ -0,0 +1,214 @@
+package sqlitestorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/models"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/storage"
+)
+
+type TokenRepository struct {
+	storage *Storage
+}
+
+func (t *TokenRepository) CreateSession(ctx context.Context, session *models.Session) (*models.Session, error) {
+	_, err := t.storage.db.ExecContext(
+		ctx,
+		"INSERT INTO sessions (id, user_id, audience, scope, dpop_jkt, access_token_id, refresh_token_hash, is_revoked, remember_me, authenticated_at, last_used_at, created_at, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)",
+		session.ID,
+		session.UserID,
+		session.Audience,
+		session.Scope,
+		session.DPoPJKT,
+		session.AccessTokenID,
+		session.RefreshTokenHash,
+		session.IsRevoked,
+		session.RememberMe,
+		session.AuthenticatedAt.UTC(),
+		session.LastUsedAt.UTC(),
+		time.Now().UTC(),
+		session.ExpiresAt.UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+const sessionColumns = "id, user_id, audience, scope, dpop_jkt, access_token_id, refresh_token_hash, is_revoked, revoked_at, remember_me, authenticated_at, last_used_at, created_at, expires_at"
+
+func (t *TokenRepository) GetSession(ctx context.Context, id string) (*models.Session, error) {
+	return t.getSession(ctx, "SELECT "+sessionColumns+" FROM sessions WHERE id = $1", id)
+}
+
+// GetSessionForUpdate needs no row lock: a transaction holds the database
+// write lock from its start.
+func (t *TokenRepository) GetSessionForUpdate(ctx context.Context, id string) (*models.Session, error) {
+	return t.GetSession(ctx, id)
+}
+
+func (t *TokenRepository) GetSessionByRefreshTokenHash(ctx context.Context, hash string) (*models.Session, error) {
+	return t.getSession(ctx, "SELECT "+sessionColumns+" FROM sessions WHERE refresh_token_hash = $1", hash)
+}
+
+func (t *TokenRepository) FindSessionsByUserID(ctx context.Context, userID string) ([]*models.Session, error) {
+	rows, err := t.storage.db.QueryContext(
+		ctx,
+		"SELECT "+sessionColumns+" FROM sessions WHERE user_id = $1 ORDER BY created_at",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*models.Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+func (t *TokenRepository) RevokeSession(ctx context.Context, id string) error {
+	_, err := t.storage.db.ExecContext(
+		ctx,
+		"UPDATE sessions SET is_revoked = true, revoked_at = COALESCE(revoked_at, $1) WHERE id = $2",
+		time.Now().UTC(),
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (t *TokenRepository) DeleteSession(ctx context.Context, id string) error {
+	_, err := t.storage.db.ExecContext(
+		ctx,
+		"DELETE FROM sessions WHERE id = $1",
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (t *TokenRepository) RotateSession(ctx context.Context, oldID string, session *models.Session) (*models.Session, error) {
+	err := t.storage.WithTx(ctx, func(tx storage.Storage) error {
+		if _, err := tx.Token().GetSessionForUpdate(ctx, oldID); err != nil {
+			return err
+		}
+
+		if err := tx.Token().DeleteSession(ctx, oldID); err != nil {
+			return err
+		}
+
+		_, err := tx.Token().CreateSession(ctx, session)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+func (t *TokenRepository) UpdateRefreshTokenHash(ctx context.Context, id string, hash string) error {
+	_, err := t.storage.db.ExecContext(
+		ctx,
+		"UPDATE sessions SET refresh_token_hash = $1 WHERE id = $2",
+		hash,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (t *TokenRepository) RecordAccessToken(ctx context.Context, id string, accessTokenID string, usedAt time.Time) error {
+	_, err := t.storage.db.ExecContext(
+		ctx,
+		"UPDATE sessions SET access_token_id = $1, last_used_at = $2 WHERE id = $3",
+		accessTokenID,
+		usedAt.UTC(),
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (t *TokenRepository) DeleteExpiredSessions(ctx context.Context, now time.Time, revokedBefore time.Time, limit int) (int64, error) {
+	res, err := t.storage.db.ExecContext(
+		ctx,
+		`DELETE FROM sessions WHERE id IN (
+			SELECT id FROM sessions
+			WHERE expires_at < $1 OR (is_revoked AND COALESCE(revoked_at, created_at) < $2)
+			LIMIT $3
+		)`,
+		now.UTC(),
+		revokedBefore.UTC(),
+		limit,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+func (t *TokenRepository) getSession(ctx context.Context, query string, arg string) (*models.Session, error) {
+	session, err := scanSession(t.storage.db.QueryRowContext(ctx, query, arg))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrRecordNotFound
+		}
+
+		return nil, err
+	}
+
+	return session, nil
+}
+
+func scanSession(row rowScanner) (*models.Session, error) {
+	session := &models.Session{}
+
+	err := row.Scan(
+		&session.ID,
+		&session.UserID,
+		&session.Audience,
+		&session.Scope,
+		&session.DPoPJKT,
+		&session.AccessTokenID,
+		&session.RefreshTokenHash,
+		&session.IsRevoked,
+		&session.RevokedAt,
+		&session.RememberMe,
+		&session.AuthenticatedAt,
+		&session.LastUsedAt,
+		&session.CreatedAt,
+		&session.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
@@ -0,0 +1,123 @@
+package sqlitestorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/models"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/storage"
+	"github.com/google/uuid"
+)
+
+type UserRepository struct {
+	storage *Storage
+}
+
+func (r *UserRepository) Create(ctx context.Context, u *models.User) error {
+	if err := u.Validate(); err != nil {
+		return err
+	}
+
+	if err := u.BeforeCreate(); err != nil {
+		return err
+	}
+
+	u.ID = uuid.New().String()
+
+	_, err := r.storage.db.ExecContext(
+		ctx,
+		"INSERT INTO users (id, email, encrypted_password, pepper_version, scopes) VALUES ($1, $2, $3, $4, $5)",
+		u.ID,
+		u.Email,
+		u.EncryptedPassword,
+		u.PepperVersion,
+		strings.Join(u.Scopes, " "),
+	)
+	if err != nil {
+		return mapError(err)
+	}
+
+	return nil
+}
+
+func (r *UserRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	return r.findUser(ctx, "SELECT id, email, encrypted_password, pepper_version, scopes, is_disabled FROM users WHERE email = $1", email)
+}
+
+func (r *UserRepository) FindByID(ctx context.Context, id string) (*models.User, error) {
+	return r.findUser(ctx, "SELECT id, email, encrypted_password, pepper_version, scopes, is_disabled FROM users WHERE id = $1", id)
+}
+
+// FindByIDForUpdate needs no row lock: a transaction holds the database
+// write lock from its start.
+func (r *UserRepository) FindByIDForUpdate(ctx context.Context, id string) (*models.User, error) {
+	return r.FindByID(ctx, id)
+}
+
+func (r *UserRepository) UpdatePassword(ctx context.Context, u *models.User) error {
+	if err := u.BeforeCreate(); err != nil {
+		return err
+	}
+
+	_, err := r.storage.db.ExecContext(
+		ctx,
+		"UPDATE users SET encrypted_password = $1, pepper_version = $2 WHERE id = $3",
+		u.EncryptedPassword,
+		u.PepperVersion,
+		u.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *UserRepository) SetDisabled(ctx context.Context, id string, disabled bool) error {
+	res, err := r.storage.db.ExecContext(
+		ctx,
+		"UPDATE users SET is_disabled = $1 WHERE id = $2",
+		disabled,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return storage.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (r *UserRepository) findUser(ctx context.Context, query string, arg string) (*models.User, error) {
+	var scopes string
+
+	u := &models.User{}
+	if err := r.storage.db.QueryRowContext(ctx, query, arg).Scan(
+		&u.ID,
+		&u.Email,
+		&u.EncryptedPassword,
+		&u.PepperVersion,
+		&scopes,
+		&u.IsDisabled,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrRecordNotFound
+		}
+
+		return nil, err
+	}
+
+	u.Scopes = strings.Fields(scopes)
+
+	return u, nil
+}
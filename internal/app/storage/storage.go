@@ -1,6 +1,13 @@
 package storage
 
+import "context"
+
 type Storage interface {
 	User() UserRepository
 	Token() TokenRepository
+	APIKey() APIKeyRepository
+	Denylist() DenylistRepository
+	// WithTx runs fn as a unit of work: everything done through the storage
+	// passed to fn is committed if fn returns nil and rolled back otherwise.
+	WithTx(ctx context.Context, fn func(Storage) error) error
 }
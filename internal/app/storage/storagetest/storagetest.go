@@ -0,0 +1,380 @@
+// Package storagetest is the contract every storage.Storage implementation
+// has to meet. An implementation runs it from its own tests:
+//
+//	func TestStorage(t *testing.T) {
+//		storagetest.Run(t, func(t *testing.T) storage.Storage { return New() })
+//	}
+package storagetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/models"
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/storage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Run runs every contract test on an empty storage returned by newStorage.
+func Run(t *testing.T, newStorage func(t *testing.T) storage.Storage) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s storage.Storage)
+	}{
+		{"UserCreate", testUserCreate},
+		{"UserCreateDuplicateEmail", testUserCreateDuplicateEmail},
+		{"UserFindNotFound", testUserFindNotFound},
+		{"UserSetDisabled", testUserSetDisabled},
+		{"UserFindForUpdate", testUserFindForUpdate},
+		{"APIKeyCreateDuplicatePrefix", testAPIKeyCreateDuplicatePrefix},
+		{"GetSessionNotFound", testGetSessionNotFound},
+		{"CreateGetSession", testCreateGetSession},
+		{"RevokeGetSession", testRevokeGetSession},
+		{"RotateSession", testRotateSession},
+		{"FindSessionsByUserID", testFindSessionsByUserID},
+		{"DeleteExpiredSessions", testDeleteExpiredSessions},
+		{"WithTxCommit", testWithTxCommit},
+		{"WithTxRollback", testWithTxRollback},
+		{"WithTxNested", testWithTxNested},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.test(t, newStorage(t))
+		})
+	}
+}
+
+func newUser(t *testing.T, s storage.Storage, email string) *models.User {
+	t.Helper()
+
+	u := &models.User{Email: email, Password: "Correct-Horse-7"}
+	require.NoError(t, s.User().Create(context.Background(), u))
+
+	return u
+}
+
+func newSession(t *testing.T, s storage.Storage, u *models.User, ttl time.Duration) *models.Session {
+	t.Helper()
+
+	now := time.Now()
+	session := &models.Session{
+		ID:               uuid.New().String(),
+		UserID:           u.ID,
+		Scope:            "profile",
+		RefreshTokenHash: uuid.New().String(),
+		AuthenticatedAt:  now,
+		LastUsedAt:       now,
+		ExpiresAt:        now.Add(ttl),
+	}
+
+	_, err := s.Token().CreateSession(context.Background(), session)
+	require.NoError(t, err)
+
+	return session
+}
+
+func testUserCreate(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	u := newUser(t, s, "user@example.org")
+
+	assert.NotEmpty(t, u.ID)
+	assert.NotEmpty(t, u.EncryptedPassword)
+
+	found, err := s.User().FindByEmail(ctx, "user@example.org")
+	require.NoError(t, err)
+	assert.Equal(t, u.ID, found.ID)
+	assert.Equal(t, u.EncryptedPassword, found.EncryptedPassword)
+
+	found, err = s.User().FindByID(ctx, u.ID)
+	require.NoError(t, err)
+	assert.Equal(t, u.Email, found.Email)
+}
+
+func testUserCreateDuplicateEmail(t *testing.T, s storage.Storage) {
+	newUser(t, s, "user@example.org")
+
+	err := s.User().Create(context.Background(), &models.User{Email: "user@example.org", Password: "Correct-Horse-7"})
+	assert.ErrorIs(t, err, storage.ErrAlreadyExists)
+}
+
+func testUserFindNotFound(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	_, err := s.User().FindByEmail(ctx, "nobody@example.org")
+	assert.ErrorIs(t, err, storage.ErrRecordNotFound)
+
+	_, err = s.User().FindByID(ctx, uuid.New().String())
+	assert.ErrorIs(t, err, storage.ErrRecordNotFound)
+}
+
+func testUserSetDisabled(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	u := newUser(t, s, "user@example.org")
+
+	require.NoError(t, s.User().SetDisabled(ctx, u.ID, true))
+
+	found, err := s.User().FindByID(ctx, u.ID)
+	require.NoError(t, err)
+	assert.True(t, found.IsDisabled)
+
+	assert.ErrorIs(t, s.User().SetDisabled(ctx, uuid.New().String(), true), storage.ErrRecordNotFound)
+}
+
+func testUserFindForUpdate(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	u := newUser(t, s, "user@example.org")
+
+	err := s.WithTx(ctx, func(tx storage.Storage) error {
+		found, err := tx.User().FindByIDForUpdate(ctx, u.ID)
+		if err != nil {
+			return err
+		}
+
+		assert.Equal(t, u.Email, found.Email)
+
+		_, err = tx.User().FindByIDForUpdate(ctx, "unknown")
+		assert.ErrorIs(t, err, storage.ErrRecordNotFound)
+
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func testAPIKeyCreateDuplicatePrefix(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	u := newUser(t, s, "user@example.org")
+
+	k := &models.APIKey{UserID: u.ID, Name: "ci", Prefix: "prefix", SecretHash: "hash"}
+	require.NoError(t, s.APIKey().Create(ctx, k))
+
+	found, err := s.APIKey().FindByPrefix(ctx, "prefix")
+	require.NoError(t, err)
+	assert.Equal(t, k.ID, found.ID)
+
+	err = s.APIKey().Create(ctx, &models.APIKey{UserID: u.ID, Name: "other", Prefix: "prefix", SecretHash: "hash"})
+	assert.ErrorIs(t, err, storage.ErrAlreadyExists)
+}
+
+func testGetSessionNotFound(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	_, err := s.Token().GetSession(ctx, uuid.New().String())
+	assert.ErrorIs(t, err, storage.ErrRecordNotFound)
+
+	_, err = s.Token().GetSessionByRefreshTokenHash(ctx, "unknown")
+	assert.ErrorIs(t, err, storage.ErrRecordNotFound)
+}
+
+func testCreateGetSession(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	u := newUser(t, s, "user@example.org")
+	session := newSession(t, s, u, time.Hour)
+
+	found, err := s.Token().GetSession(ctx, session.ID)
+	require.NoError(t, err)
+	assert.Equal(t, u.ID, found.UserID)
+	assert.Equal(t, session.Scope, found.Scope)
+	assert.WithinDuration(t, session.ExpiresAt, found.ExpiresAt, time.Millisecond)
+	assert.False(t, found.IsRevoked)
+
+	found, err = s.Token().GetSessionByRefreshTokenHash(ctx, session.RefreshTokenHash)
+	require.NoError(t, err)
+	assert.Equal(t, session.ID, found.ID)
+
+	require.NoError(t, s.Token().UpdateRefreshTokenHash(ctx, session.ID, "rotated"))
+	found, err = s.Token().GetSessionByRefreshTokenHash(ctx, "rotated")
+	require.NoError(t, err)
+	assert.Equal(t, session.ID, found.ID)
+
+	usedAt := time.Now().Add(time.Minute)
+	require.NoError(t, s.Token().RecordAccessToken(ctx, session.ID, "jti", usedAt))
+	found, err = s.Token().GetSession(ctx, session.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "jti", found.AccessTokenID)
+	assert.WithinDuration(t, usedAt, found.LastUsedAt, time.Millisecond)
+
+	require.NoError(t, s.Token().DeleteSession(ctx, session.ID))
+	_, err = s.Token().GetSession(ctx, session.ID)
+	assert.ErrorIs(t, err, storage.ErrRecordNotFound)
+}
+
+func testRevokeGetSession(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	u := newUser(t, s, "user@example.org")
+	session := newSession(t, s, u, time.Hour)
+
+	require.NoError(t, s.Token().RevokeSession(ctx, session.ID))
+
+	found, err := s.Token().GetSession(ctx, session.ID)
+	require.NoError(t, err)
+	assert.True(t, found.IsRevoked)
+	require.NotNil(t, found.RevokedAt)
+
+	// revoking again keeps the original time
+	revokedAt := *found.RevokedAt
+	require.NoError(t, s.Token().RevokeSession(ctx, session.ID))
+
+	found, err = s.Token().GetSession(ctx, session.ID)
+	require.NoError(t, err)
+	assert.True(t, revokedAt.Equal(*found.RevokedAt))
+}
+
+func testRotateSession(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	u := newUser(t, s, "user@example.org")
+	old := newSession(t, s, u, time.Hour)
+
+	now := time.Now()
+	session := &models.Session{
+		ID:               uuid.New().String(),
+		UserID:           u.ID,
+		RefreshTokenHash: uuid.New().String(),
+		AuthenticatedAt:  now,
+		LastUsedAt:       now,
+		ExpiresAt:        now.Add(time.Hour),
+	}
+
+	_, err := s.Token().RotateSession(ctx, old.ID, session)
+	require.NoError(t, err)
+
+	_, err = s.Token().GetSession(ctx, old.ID)
+	assert.ErrorIs(t, err, storage.ErrRecordNotFound)
+	_, err = s.Token().GetSessionByRefreshTokenHash(ctx, old.RefreshTokenHash)
+	assert.ErrorIs(t, err, storage.ErrRecordNotFound)
+
+	found, err := s.Token().GetSessionByRefreshTokenHash(ctx, session.RefreshTokenHash)
+	require.NoError(t, err)
+	assert.Equal(t, session.ID, found.ID)
+
+	sessions, err := s.Token().FindSessionsByUserID(ctx, u.ID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, session.ID, sessions[0].ID)
+
+	// the old session is gone, so rotating it again creates nothing
+	again := *session
+	again.ID = uuid.New().String()
+	again.RefreshTokenHash = uuid.New().String()
+	_, err = s.Token().RotateSession(ctx, old.ID, &again)
+	assert.ErrorIs(t, err, storage.ErrRecordNotFound)
+	_, err = s.Token().GetSession(ctx, again.ID)
+	assert.ErrorIs(t, err, storage.ErrRecordNotFound)
+}
+
+func testFindSessionsByUserID(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	u := newUser(t, s, "user@example.org")
+	other := newUser(t, s, "other@example.org")
+
+	first := newSession(t, s, u, time.Hour)
+	time.Sleep(2 * time.Millisecond)
+	second := newSession(t, s, u, time.Hour)
+	newSession(t, s, other, time.Hour)
+
+	sessions, err := s.Token().FindSessionsByUserID(ctx, u.ID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, first.ID, sessions[0].ID)
+	assert.Equal(t, second.ID, sessions[1].ID)
+}
+
+// testDeleteExpiredSessions allows expired sessions to be gone before the
+// purge, for stores that expire them on their own.
+func testDeleteExpiredSessions(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	u := newUser(t, s, "user@example.org")
+
+	live := newSession(t, s, u, time.Hour)
+	expired := newSession(t, s, u, -time.Minute)
+	revoked := newSession(t, s, u, time.Hour)
+	require.NoError(t, s.Token().RevokeSession(ctx, revoked.ID))
+
+	// revoked sessions are kept until revokedBefore passes them
+	_, err := s.Token().DeleteExpiredSessions(ctx, time.Now(), time.Now().Add(-time.Hour), 100)
+	require.NoError(t, err)
+	_, err = s.Token().GetSession(ctx, revoked.ID)
+	assert.NoError(t, err)
+
+	_, err = s.Token().DeleteExpiredSessions(ctx, time.Now(), time.Now().Add(time.Minute), 100)
+	require.NoError(t, err)
+
+	_, err = s.Token().GetSession(ctx, live.ID)
+	assert.NoError(t, err)
+	_, err = s.Token().GetSession(ctx, expired.ID)
+	assert.ErrorIs(t, err, storage.ErrRecordNotFound)
+	_, err = s.Token().GetSession(ctx, revoked.ID)
+	assert.ErrorIs(t, err, storage.ErrRecordNotFound)
+}
+
+func testWithTxCommit(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	var (
+		u       *models.User
+		session *models.Session
+	)
+	err := s.WithTx(ctx, func(tx storage.Storage) error {
+		u = newUser(t, tx, "user@example.org")
+		session = newSession(t, tx, u, time.Hour)
+
+		// seen inside the transaction
+		_, err := tx.User().FindByIDForUpdate(ctx, u.ID)
+		return err
+	})
+	require.NoError(t, err)
+
+	_, err = s.User().FindByID(ctx, u.ID)
+	assert.NoError(t, err)
+	_, err = s.Token().GetSession(ctx, session.ID)
+	assert.NoError(t, err)
+}
+
+func testWithTxRollback(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	errRollback := assert.AnError
+	err := s.WithTx(ctx, func(tx storage.Storage) error {
+		newUser(t, tx, "user@example.org")
+		return errRollback
+	})
+	assert.ErrorIs(t, err, errRollback)
+
+	_, err = s.User().FindByEmail(ctx, "user@example.org")
+	assert.ErrorIs(t, err, storage.ErrRecordNotFound)
+}
+
+// testWithTxNested checks that WithTx called in a transaction joins it
+// instead of committing on its own.
+func testWithTxNested(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	errRollback := assert.AnError
+	err := s.WithTx(ctx, func(tx storage.Storage) error {
+		err := tx.WithTx(ctx, func(tx storage.Storage) error {
+			newUser(t, tx, "user@example.org")
+			return nil
+		})
+		require.NoError(t, err)
+
+		return errRollback
+	})
+	assert.ErrorIs(t, err, errRollback)
+
+	_, err = s.User().FindByEmail(ctx, "user@example.org")
+	assert.ErrorIs(t, err, storage.ErrRecordNotFound)
+
+	err = s.WithTx(ctx, func(tx storage.Storage) error {
+		return tx.WithTx(ctx, func(tx storage.Storage) error {
+			newUser(t, tx, "user@example.org")
+			return nil
+		})
+	})
+	require.NoError(t, err)
+
+	_, err = s.User().FindByEmail(ctx, "user@example.org")
+	assert.NoError(t, err)
+}
@@ -1,10 +1,30 @@
 package storage
 
-import "github.com/andreyxaxa/rest_auth_svc/internal/app/models"
+import (
+	"context"
+	"time"
+
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/models"
+)
 
 type TokenRepository interface {
-	CreateSession(*models.Session) (*models.Session, error)
-	GetSession(string) (*models.Session, error)
-	RevokeSession(string) error
-	DeleteSession(string) error
+	CreateSession(ctx context.Context, session *models.Session) (*models.Session, error)
+	GetSession(ctx context.Context, id string) (*models.Session, error)
+	// GetSessionForUpdate is GetSession that also locks the session until the
+	// end of the transaction it runs in, see Storage.WithTx.
+	GetSessionForUpdate(ctx context.Context, id string) (*models.Session, error)
+	GetSessionByRefreshTokenHash(ctx context.Context, hash string) (*models.Session, error)
+	FindSessionsByUserID(ctx context.Context, userID string) ([]*models.Session, error)
+	RevokeSession(ctx context.Context, id string) error
+	DeleteSession(ctx context.Context, id string) error
+	// RotateSession deletes the session oldID and creates session in its place
+	// at once, so that no reader sees both or neither. It fails with
+	// ErrRecordNotFound, creating nothing, if oldID does not exist.
+	RotateSession(ctx context.Context, oldID string, session *models.Session) (*models.Session, error)
+	UpdateRefreshTokenHash(ctx context.Context, id string, hash string) error
+	// RecordAccessToken marks the session used at usedAt by issuing the access token accessTokenID.
+	RecordAccessToken(ctx context.Context, id string, accessTokenID string, usedAt time.Time) error
+	// DeleteExpiredSessions deletes up to limit sessions that expired before now
+	// or were revoked before revokedBefore, and returns how many it deleted.
+	DeleteExpiredSessions(ctx context.Context, now time.Time, revokedBefore time.Time, limit int) (int64, error)
 }
@@ -1,9 +1,18 @@
 package storage
 
-import "github.com/andreyxaxa/rest_auth_svc/internal/app/models"
+import (
+	"context"
+
+	"github.com/andreyxaxa/rest_auth_svc/internal/app/models"
+)
 
 type UserRepository interface {
-	Create(*models.User) error
-	FindByEmail(string) (*models.User, error)
-	FindByID(string) (*models.User, error)
+	Create(ctx context.Context, u *models.User) error
+	FindByEmail(ctx context.Context, email string) (*models.User, error)
+	FindByID(ctx context.Context, id string) (*models.User, error)
+	// FindByIDForUpdate is FindByID that also locks the user until the end of
+	// the transaction it runs in, see Storage.WithTx.
+	FindByIDForUpdate(ctx context.Context, id string) (*models.User, error)
+	UpdatePassword(ctx context.Context, u *models.User) error
+	SetDisabled(ctx context.Context, id string, disabled bool) error
 }
@@ -0,0 +1,77 @@
+package token
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+)
+
+const (
+	APIKeyPrefix = "ak_"
+
+	apiKeyLookupLen   = 8
+	apiKeySecretBytes = 32
+)
+
+// NewAPIKey returns a personal API key of the form ak_<lookup>_<secret>.
+// lookup is stored in clear to find the key, only the digest of secret is stored.
+func NewAPIKey() (key string, lookup string, secretHash string, err error) {
+	lookup, err = randomBase62(apiKeyLookupLen)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	b := make([]byte, apiKeySecretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", "", err
+	}
+	secret := encodeBase62(b)
+
+	return APIKeyPrefix + lookup + "_" + secret, lookup, HashAPIKeySecret(secret), nil
+}
+
+// ParseAPIKey splits key into its lookup part and secret.
+func ParseAPIKey(key string) (lookup string, secret string, ok bool) {
+	rest, ok := strings.CutPrefix(key, APIKeyPrefix)
+	if !ok {
+		return "", "", false
+	}
+
+	lookup, secret, ok = strings.Cut(rest, "_")
+	if !ok || len(lookup) != apiKeyLookupLen || secret == "" {
+		return "", "", false
+	}
+
+	return lookup, secret, true
+}
+
+func HashAPIKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func VerifyAPIKeySecret(secret string, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashAPIKeySecret(secret)), []byte(hash)) == 1
+}
+
+func randomBase62(n int) (string, error) {
+	const maxByte = 256 - 256%len(base62Alphabet) // reject bytes that would bias the modulo
+
+	out := make([]byte, 0, n)
+	buf := make([]byte, n)
+	for len(out) < n {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+
+		for _, b := range buf {
+			if int(b) < maxByte && len(out) < n {
+				out = append(out, base62Alphabet[int(b)%len(base62Alphabet)])
+			}
+		}
+	}
+
+	return string(out), nil
+}
@@ -0,0 +1,24 @@
+package token
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIKey(t *testing.T) {
+	key, lookup, hash, err := NewAPIKey()
+	assert.NoError(t, err)
+	assert.Len(t, lookup, apiKeyLookupLen)
+
+	gotLookup, secret, ok := ParseAPIKey(key)
+	assert.True(t, ok)
+	assert.Equal(t, lookup, gotLookup)
+	assert.True(t, VerifyAPIKeySecret(secret, hash))
+	assert.False(t, VerifyAPIKeySecret(secret+"x", hash))
+
+	for _, bad := range []string{"", "ak_", "ak_short_secret", "rt_abcdefgh_secret", "ak_abcdefgh_"} {
+		_, _, ok := ParseAPIKey(bad)
+		assert.False(t, ok, bad)
+	}
+}
@@ -7,28 +7,84 @@ import (
 	"github.com/google/uuid"
 )
 
+const (
+	UseAccess  = "access"
+	UseRefresh = "refresh"
+)
+
 type UserClaims struct {
-	ID    string `json:"id"`
-	Email string `json:"email"`
-	IP    string `json:"ip"`
+	ID           string        `json:"id"`
+	Email        string        `json:"email"`
+	IP           string        `json:"ip"`
+	TokenUse     string        `json:"token_use"`
+	Scope        string        `json:"scope,omitempty"`
+	Confirmation *Confirmation `json:"cnf,omitempty"`
+	SessionID    string        `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func NewUserClaims(id string, email string, ip string, duration time.Duration) (*UserClaims, error) {
-	tokenID, err := uuid.NewRandom()
-	if err != nil {
-		return nil, err
+// Confirmation binds a token to a proof-of-possession key (RFC 7800).
+type Confirmation struct {
+	// JKT is the SHA-256 JWK thumbprint of the DPoP key (RFC 9449).
+	JKT string `json:"jkt"`
+}
+
+// Params describe a token to be issued.
+type Params struct {
+	// ID is the token ID (jti), generated when empty.
+	ID       string
+	Use      string // UseAccess or UseRefresh
+	UserID   string
+	Email    string
+	IP       string
+	Duration time.Duration
+	// Audience overrides the maker's default audience when set.
+	Audience []string
+	Scopes   []string
+	// JKT binds the token to a DPoP key when set.
+	JKT string
+	// SessionID names the session an access token was issued in.
+	SessionID string
+}
+
+func NewUserClaims(p *Params, opts *Options) (*UserClaims, error) {
+	tokenID := p.ID
+	if tokenID == "" {
+		id, err := uuid.NewRandom()
+		if err != nil {
+			return nil, err
+		}
+		tokenID = id.String()
 	}
 
+	audience := p.Audience
+	if len(audience) == 0 {
+		audience = opts.Audience
+	}
+
+	var cnf *Confirmation
+	if p.JKT != "" {
+		cnf = &Confirmation{JKT: p.JKT}
+	}
+
+	now := time.Now()
+
 	return &UserClaims{
-		ID:    id,
-		Email: email,
-		IP:    ip,
+		ID:           p.UserID,
+		Email:        p.Email,
+		IP:           p.IP,
+		TokenUse:     p.Use,
+		Scope:        FormatScope(p.Scopes),
+		Confirmation: cnf,
+		SessionID:    p.SessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ID:        tokenID.String(),
-			Subject:   email,
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(duration)),
+			ID:        tokenID,
+			Issuer:    opts.Issuer,
+			Subject:   p.Email,
+			Audience:  audience,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(p.Duration)),
 		},
 	}, nil
 }
@@ -2,23 +2,24 @@ package token
 
 import (
 	"fmt"
-	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
 type JWTMaker struct {
 	secretKey string
+	opts      *Options
 }
 
-func NewJWTMaker(secretKey string) *JWTMaker {
+func NewJWTMaker(secretKey string, opts *Options) *JWTMaker {
 	return &JWTMaker{
 		secretKey: secretKey,
+		opts:      opts,
 	}
 }
 
-func (m *JWTMaker) CreateToken(id string, email string, ip string, duration time.Duration) (string, *UserClaims, error) {
-	claims, err := NewUserClaims(id, email, ip, duration)
+func (m *JWTMaker) CreateToken(p *Params) (string, *UserClaims, error) {
+	claims, err := NewUserClaims(p, m.opts)
 	if err != nil {
 		return "", nil, err
 	}
@@ -41,7 +42,7 @@ func (m *JWTMaker) VerifyToken(tokenStr string) (*UserClaims, error) {
 		}
 
 		return []byte(m.secretKey), nil
-	})
+	}, jwt.WithoutClaimsValidation())
 
 	if err != nil {
 		return nil, err
@@ -52,5 +53,9 @@ func (m *JWTMaker) VerifyToken(tokenStr string) (*UserClaims, error) {
 		return nil, fmt.Errorf("invalid token claims")
 	}
 
+	if err := m.opts.validate(claims); err != nil {
+		return nil, err
+	}
+
 	return claims, nil
 }
@@ -0,0 +1,151 @@
+package token
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+const (
+	TypeJWT          = "jwt"
+	TypePasetoLocal  = "paseto.v4.local"
+	TypePasetoPublic = "paseto.v4.public"
+)
+
+var (
+	_ Maker = (*JWTMaker)(nil)
+	_ Maker = (*PasetoMaker)(nil)
+)
+
+var ErrWrongTokenUse = errors.New("wrong token use")
+
+// Maker creates and verifies signed or encrypted user tokens.
+type Maker interface {
+	CreateToken(p *Params) (string, *UserClaims, error)
+	VerifyToken(tokenStr string) (*UserClaims, error)
+}
+
+// VerifyAccessToken verifies tokenStr and checks that it was issued as an access token.
+func VerifyAccessToken(m Maker, tokenStr string) (*UserClaims, error) {
+	return verifyTokenUse(m, tokenStr, UseAccess)
+}
+
+// VerifyRefreshToken verifies tokenStr and checks that it was issued as a refresh token.
+//
+// Deprecated behaviour: refresh tokens issued before token_use was added carry
+// no token_use. Those issued before legacyBefore are still accepted, so that
+// users stay signed in across the upgrade; a zero legacyBefore accepts none.
+// Callers must match such a token against its stored session, which an access
+// token never does.
+func VerifyRefreshToken(m Maker, tokenStr string, legacyBefore time.Time) (*UserClaims, error) {
+	claims, err := verifyTokenUse(m, tokenStr, UseRefresh, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.TokenUse == "" && (claims.IssuedAt == nil || !claims.IssuedAt.Before(legacyBefore)) {
+		return nil, fmt.Errorf("%w: refresh token without token_use issued after the cutoff", ErrWrongTokenUse)
+	}
+
+	return claims, nil
+}
+
+func verifyTokenUse(m Maker, tokenStr string, use string, also ...string) (*UserClaims, error) {
+	claims, err := m.VerifyToken(tokenStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.TokenUse != use && !slices.Contains(also, claims.TokenUse) {
+		return nil, fmt.Errorf("%w: expected %s token, got %q", ErrWrongTokenUse, use, claims.TokenUse)
+	}
+
+	return claims, nil
+}
+
+type AudienceConfig struct {
+	AccessTTL  time.Duration `toml:"access_ttl"`
+	RefreshTTL time.Duration `toml:"refresh_ttl"`
+}
+
+type Config struct {
+	Type string `toml:"type"`
+	// PasetoKey is hex encoded: a 32-byte symmetric key for v4.local,
+	// a 64-byte Ed25519 secret key or 32-byte seed for v4.public.
+	PasetoKey  string        `toml:"paseto_key"`
+	Issuer     string        `toml:"issuer"`
+	Audience   []string      `toml:"audience"`
+	AccessTTL  time.Duration `toml:"access_ttl"`
+	RefreshTTL time.Duration `toml:"refresh_ttl"`
+	Leeway     time.Duration `toml:"leeway"`
+	// LegacyRefreshBefore accepts refresh tokens without token_use issued
+	// before it; zero accepts none. Set it to when token_use was deployed.
+	LegacyRefreshBefore time.Time `toml:"legacy_refresh_before"`
+	// Audiences holds per-audience (per-client) lifetime overrides. Their
+	// names are accepted as token audiences alongside Audience.
+	Audiences map[string]*AudienceConfig `toml:"audiences"`
+}
+
+func NewConfig() *Config {
+	return &Config{
+		Type:       TypeJWT,
+		AccessTTL:  15 * time.Minute,
+		RefreshTTL: 24 * time.Hour,
+	}
+}
+
+// AllowsAudience reports whether tokens may be issued for aud.
+func (c *Config) AllowsAudience(aud string) bool {
+	if _, ok := c.Audiences[aud]; ok {
+		return true
+	}
+
+	return slices.Contains(c.Audience, aud)
+}
+
+// AccessTTLFor returns the access token lifetime for aud, falling back to AccessTTL.
+func (c *Config) AccessTTLFor(aud string) time.Duration {
+	if o, ok := c.Audiences[aud]; ok && o.AccessTTL > 0 {
+		return o.AccessTTL
+	}
+
+	return c.AccessTTL
+}
+
+// RefreshTTLFor returns the refresh token lifetime for aud, falling back to RefreshTTL.
+func (c *Config) RefreshTTLFor(aud string) time.Duration {
+	if o, ok := c.Audiences[aud]; ok && o.RefreshTTL > 0 {
+		return o.RefreshTTL
+	}
+
+	return c.RefreshTTL
+}
+
+func (c *Config) options() *Options {
+	opts := &Options{
+		Issuer:   c.Issuer,
+		Audience: c.Audience,
+		Leeway:   c.Leeway,
+	}
+
+	for aud := range c.Audiences {
+		opts.ExtraAudiences = append(opts.ExtraAudiences, aud)
+	}
+
+	return opts
+}
+
+// NewMaker returns the Maker selected in config. jwtSecretKey is used by the JWT maker.
+func NewMaker(config *Config, jwtSecretKey string) (Maker, error) {
+	switch config.Type {
+	case TypeJWT:
+		return NewJWTMaker(jwtSecretKey, config.options()), nil
+	case TypePasetoLocal:
+		return NewPasetoLocalMaker(config.PasetoKey, config.options())
+	case TypePasetoPublic:
+		return NewPasetoPublicMaker(config.PasetoKey, config.options())
+	default:
+		return nil, fmt.Errorf("unknown token type %q", config.Type)
+	}
+}
@@ -0,0 +1,163 @@
+package token
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"aidanwoods.dev/go-paseto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaker_CreateAndVerify(t *testing.T) {
+	testCases := []struct {
+		name   string
+		config *Config
+		prefix string
+	}{
+		{
+			name:   "jwt",
+			config: &Config{Type: TypeJWT},
+			prefix: "eyJ",
+		},
+		{
+			name:   "paseto local",
+			config: &Config{Type: TypePasetoLocal, PasetoKey: paseto.NewV4SymmetricKey().ExportHex()},
+			prefix: "v4.local.",
+		},
+		{
+			name:   "paseto public",
+			config: &Config{Type: TypePasetoPublic, PasetoKey: paseto.NewV4AsymmetricSecretKey().ExportHex()},
+			prefix: "v4.public.",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := NewMaker(tc.config, "secret")
+			assert.NoError(t, err)
+
+			tokenStr, claims, err := m.CreateToken(&Params{UserID: "id", Email: "user@example.com", IP: "127.0.0.1", Duration: time.Minute})
+			assert.NoError(t, err)
+			assert.True(t, strings.HasPrefix(tokenStr, tc.prefix))
+
+			verified, err := m.VerifyToken(tokenStr)
+			assert.NoError(t, err)
+			assert.Equal(t, claims.RegisteredClaims.ID, verified.RegisteredClaims.ID)
+			assert.Equal(t, "user@example.com", verified.Email)
+
+			_, err = m.VerifyToken(tokenStr[:len(tokenStr)-2] + "xx")
+			assert.Error(t, err)
+
+			expired, _, err := m.CreateToken(&Params{UserID: "id", Email: "user@example.com", IP: "127.0.0.1", Duration: -time.Minute})
+			assert.NoError(t, err)
+			_, err = m.VerifyToken(expired)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestMaker_IssuerAndAudience(t *testing.T) {
+	config := NewConfig()
+	config.Issuer = "auth"
+	config.Audience = []string{"api"}
+	config.Audiences = map[string]*AudienceConfig{"cli": {AccessTTL: time.Hour}}
+
+	m, err := NewMaker(config, "secret")
+	assert.NoError(t, err)
+
+	tokenStr, claims, err := m.CreateToken(&Params{UserID: "id", Duration: time.Minute})
+	assert.NoError(t, err)
+	assert.Equal(t, "auth", claims.Issuer)
+	assert.Equal(t, []string{"api"}, []string(claims.Audience))
+	_, err = m.VerifyToken(tokenStr)
+	assert.NoError(t, err)
+
+	tokenStr, _, err = m.CreateToken(&Params{UserID: "id", Duration: time.Minute, Audience: []string{"cli"}})
+	assert.NoError(t, err)
+	_, err = m.VerifyToken(tokenStr)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Hour, config.AccessTTLFor("cli"))
+	assert.Equal(t, 24*time.Hour, config.RefreshTTLFor("cli"))
+
+	tokenStr, _, err = m.CreateToken(&Params{UserID: "id", Duration: time.Minute, Audience: []string{"other"}})
+	assert.NoError(t, err)
+	_, err = m.VerifyToken(tokenStr)
+	assert.ErrorIs(t, err, ErrInvalidAudience)
+
+	other := NewConfig()
+	other.Issuer = "someone-else"
+	m2, err := NewMaker(other, "secret")
+	assert.NoError(t, err)
+	tokenStr, _, err = m2.CreateToken(&Params{UserID: "id", Duration: time.Minute})
+	assert.NoError(t, err)
+	_, err = m.VerifyToken(tokenStr)
+	assert.Error(t, err)
+}
+
+func TestVerifyTokenUse(t *testing.T) {
+	m, err := NewMaker(NewConfig(), "secret")
+	assert.NoError(t, err)
+
+	access, _, err := m.CreateToken(&Params{Use: UseAccess, UserID: "id", Duration: time.Minute})
+	assert.NoError(t, err)
+	refresh, _, err := m.CreateToken(&Params{Use: UseRefresh, UserID: "id", Duration: time.Minute})
+	assert.NoError(t, err)
+
+	_, err = VerifyAccessToken(m, access)
+	assert.NoError(t, err)
+	_, err = VerifyRefreshToken(m, refresh, time.Time{})
+	assert.NoError(t, err)
+
+	_, err = VerifyRefreshToken(m, access, time.Time{})
+	assert.ErrorIs(t, err, ErrWrongTokenUse)
+	_, err = VerifyAccessToken(m, refresh)
+	assert.ErrorIs(t, err, ErrWrongTokenUse)
+
+	// issued before token_use: never an access token
+	legacy, _, err := m.CreateToken(&Params{UserID: "id", Duration: time.Minute})
+	assert.NoError(t, err)
+	_, err = VerifyAccessToken(m, legacy)
+	assert.ErrorIs(t, err, ErrWrongTokenUse)
+}
+
+func TestVerifyRefreshToken_LegacyCutoff(t *testing.T) {
+	m, err := NewMaker(NewConfig(), "secret")
+	assert.NoError(t, err)
+
+	// issued now, without token_use
+	legacy, _, err := m.CreateToken(&Params{UserID: "id", Duration: time.Minute})
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name         string
+		legacyBefore time.Time
+		wantErr      error
+	}{
+		{
+			name:    "no cutoff",
+			wantErr: ErrWrongTokenUse,
+		},
+		{
+			name:         "issued before the cutoff",
+			legacyBefore: time.Now().Add(time.Hour),
+		},
+		{
+			name:         "issued after the cutoff",
+			legacyBefore: time.Now().Add(-time.Hour),
+			wantErr:      ErrWrongTokenUse,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := VerifyRefreshToken(m, legacy, tc.legacyBefore)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
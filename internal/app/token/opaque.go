@@ -0,0 +1,47 @@
+package token
+
+import (
+	"crypto/rand"
+	"math/big"
+	"strings"
+)
+
+const (
+	OpaqueRefreshTokenPrefix = "rt_"
+
+	opaqueTokenBytes = 32
+	base62Alphabet   = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+)
+
+// NewOpaqueRefreshToken returns a random refresh token of the form rt_<base62>.
+// It carries no claims; the session it belongs to is found by its digest.
+func NewOpaqueRefreshToken() (string, error) {
+	b := make([]byte, opaqueTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return OpaqueRefreshTokenPrefix + encodeBase62(b), nil
+}
+
+func IsOpaqueRefreshToken(token string) bool {
+	return strings.HasPrefix(token, OpaqueRefreshTokenPrefix)
+}
+
+func encodeBase62(b []byte) string {
+	n := new(big.Int).SetBytes(b)
+	base := big.NewInt(int64(len(base62Alphabet)))
+	mod := new(big.Int)
+
+	var out []byte
+	for n.Sign() > 0 {
+		n.DivMod(n, base, mod)
+		out = append(out, base62Alphabet[mod.Int64()])
+	}
+
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+
+	return string(out)
+}
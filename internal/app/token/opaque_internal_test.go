@@ -0,0 +1,21 @@
+package token
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewOpaqueRefreshToken(t *testing.T) {
+	a, err := NewOpaqueRefreshToken()
+	assert.NoError(t, err)
+	b, err := NewOpaqueRefreshToken()
+	assert.NoError(t, err)
+
+	assert.NotEqual(t, a, b)
+	assert.True(t, IsOpaqueRefreshToken(a))
+	assert.False(t, IsOpaqueRefreshToken("eyJhbGciOiJIUzUxMiJ9.e30.sig"))
+	assert.Greater(t, len(a), 40)
+	assert.Empty(t, strings.Trim(strings.TrimPrefix(a, OpaqueRefreshTokenPrefix), base62Alphabet))
+}
@@ -0,0 +1,54 @@
+package token
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrInvalidAudience = errors.New("token has invalid audience")
+
+// Options are the registered claims a Maker sets on issued tokens and checks on verification.
+type Options struct {
+	Issuer string
+	// Audience is set on tokens issued without an explicit audience.
+	Audience []string
+	// ExtraAudiences are accepted on verification in addition to Audience.
+	ExtraAudiences []string
+	// Leeway is the allowed clock skew for exp, nbf and iat.
+	Leeway time.Duration
+}
+
+func (o *Options) validate(claims *UserClaims) error {
+	opts := []jwt.ParserOption{
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(o.Leeway),
+	}
+	if o.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(o.Issuer))
+	}
+
+	if err := jwt.NewValidator(opts...).Validate(claims); err != nil {
+		return err
+	}
+
+	// tokens without aud are only issued when no default audience is configured
+	if len(claims.Audience) == 0 {
+		if len(o.Audience) > 0 {
+			return ErrInvalidAudience
+		}
+
+		return nil
+	}
+
+	for _, aud := range claims.Audience {
+		if slices.Contains(o.Audience, aud) || slices.Contains(o.ExtraAudiences, aud) {
+			return nil
+		}
+	}
+
+	return ErrInvalidAudience
+}
@@ -0,0 +1,117 @@
+package token
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	legacyBcryptChunkLen = 72 // bcrypt input limit the legacy hashes were split by
+	legacyBcryptHashLen  = 60
+)
+
+// RefreshTokenHasher computes keyed SHA-256 digests of refresh tokens.
+// Digests are deterministic, so they can be indexed and looked up, and they
+// are compared in constant time.
+type RefreshTokenHasher struct {
+	key []byte
+}
+
+func NewRefreshTokenHasher(key string) *RefreshTokenHasher {
+	return &RefreshTokenHasher{
+		key: []byte(key),
+	}
+}
+
+// Hash returns the hex encoded HMAC-SHA-256 of token.
+func (h *RefreshTokenHasher) Hash(token string) string {
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write([]byte(token))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether hash was produced from token. Hashes stored before
+// the switch to HMAC (raw JWTs and per-72-byte bcrypt chunks) are accepted too;
+// use IsLegacy to find out whether the stored hash should be replaced. Values
+// in no known format never verify.
+func (h *RefreshTokenHasher) Verify(token string, hash string) bool {
+	if token == "" || hash == "" {
+		return false
+	}
+
+	switch {
+	case isDigest(hash):
+		return hmac.Equal([]byte(h.Hash(token)), []byte(hash))
+	case isLegacyBcrypt(hash):
+		return verifyLegacyBcrypt(token, hash)
+	case isLegacyRaw(hash):
+		return subtle.ConstantTimeCompare([]byte(token), []byte(hash)) == 1
+	default:
+		return false
+	}
+}
+
+// IsLegacy reports whether hash was stored in a pre-HMAC format.
+func (h *RefreshTokenHasher) IsLegacy(hash string) bool {
+	return isLegacyBcrypt(hash) || isLegacyRaw(hash)
+}
+
+func isDigest(hash string) bool {
+	if len(hash) != sha256.Size*2 {
+		return false
+	}
+
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
+
+func isLegacyBcrypt(hash string) bool {
+	return strings.HasPrefix(hash, "$2") && len(hash)%legacyBcryptHashLen == 0
+}
+
+// isLegacyRaw reports whether hash is a refresh JWT stored as is, which is
+// what the first version of the service did.
+func isLegacyRaw(hash string) bool {
+	parts := strings.Split(hash, ".")
+	if len(parts) != 3 || !strings.HasPrefix(hash, "eyJ") {
+		return false
+	}
+
+	for _, part := range parts {
+		if part == "" {
+			return false
+		}
+
+		if _, err := base64.RawURLEncoding.DecodeString(part); err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+func verifyLegacyBcrypt(token string, hash string) bool {
+	chunks := (len(token) + legacyBcryptChunkLen - 1) / legacyBcryptChunkLen
+	if len(hash) != chunks*legacyBcryptHashLen {
+		return false
+	}
+
+	for i := 0; i < chunks; i++ {
+		end := min((i+1)*legacyBcryptChunkLen, len(token))
+		part := token[i*legacyBcryptChunkLen : end]
+		partHash := hash[i*legacyBcryptHashLen : (i+1)*legacyBcryptHashLen]
+
+		if bcrypt.CompareHashAndPassword([]byte(partHash), []byte(part)) != nil {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,61 @@
+package token
+
+import (
+	"slices"
+	"strings"
+)
+
+// ParseScope splits a space-delimited scope string as used in the scope claim.
+func ParseScope(scope string) []string {
+	return strings.Fields(scope)
+}
+
+func FormatScope(scopes []string) string {
+	return strings.Join(scopes, " ")
+}
+
+// GrantScopes returns the requested scopes that are also allowed.
+// If nothing is requested, every allowed scope is granted.
+func GrantScopes(requested []string, allowed []string) []string {
+	if len(requested) == 0 {
+		return slices.Clone(allowed)
+	}
+
+	granted := make([]string, 0, len(requested))
+	for _, scope := range requested {
+		if slices.Contains(allowed, scope) && !slices.Contains(granted, scope) {
+			granted = append(granted, scope)
+		}
+	}
+
+	return granted
+}
+
+// IntersectScopes returns the scopes in granted that are still allowed.
+// Unlike GrantScopes, nothing granted yields nothing.
+func IntersectScopes(granted []string, allowed []string) []string {
+	scopes := make([]string, 0, len(granted))
+	for _, scope := range granted {
+		if slices.Contains(allowed, scope) && !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return scopes
+}
+
+// HasScopes reports whether every one of required is in granted.
+func HasScopes(granted []string, required ...string) bool {
+	for _, scope := range required {
+		if !slices.Contains(granted, scope) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Scopes returns the scopes granted by the scope claim.
+func (c *UserClaims) Scopes() []string {
+	return ParseScope(c.Scope)
+}
@@ -0,0 +1,26 @@
+package token
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrantScopes(t *testing.T) {
+	allowed := []string{"profile", "keys:read"}
+
+	assert.Equal(t, allowed, GrantScopes(nil, allowed))
+	assert.Equal(t, []string{"keys:read"}, GrantScopes(ParseScope("keys:read admin keys:read"), allowed))
+	assert.Empty(t, GrantScopes(ParseScope("admin"), allowed))
+
+	assert.True(t, HasScopes(allowed, "profile"))
+	assert.True(t, HasScopes(allowed))
+	assert.False(t, HasScopes(allowed, "profile", "admin"))
+}
+
+func TestIntersectScopes(t *testing.T) {
+	allowed := []string{"profile", "keys:read"}
+
+	assert.Empty(t, IntersectScopes(nil, allowed))
+	assert.Equal(t, []string{"keys:read"}, IntersectScopes(ParseScope("keys:read admin keys:read"), allowed))
+}
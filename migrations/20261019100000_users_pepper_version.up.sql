@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN pepper_version INTEGER NOT NULL DEFAULT 0;
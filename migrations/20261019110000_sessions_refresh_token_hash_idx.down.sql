@@ -0,0 +1 @@
+DROP INDEX IF EXISTS sessions_refresh_token_hash_idx;
@@ -0,0 +1,6 @@
+-- refresh_token_hash now holds a hex HMAC-SHA-256 digest that sessions can be looked up by.
+-- Rows created before that keep their raw or bcrypt-chunked value and are rehashed
+-- on the next successful /tokens/renew; rows that already expired are dropped.
+DELETE FROM sessions WHERE expires_at < CURRENT_TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS sessions_refresh_token_hash_idx ON sessions (refresh_token_hash);
@@ -0,0 +1,3 @@
+ALTER TABLE sessions
+    ALTER COLUMN created_at TYPE TIMESTAMP,
+    ALTER COLUMN expires_at TYPE TIMESTAMP;
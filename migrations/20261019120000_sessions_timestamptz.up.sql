@@ -0,0 +1,4 @@
+-- opaque refresh tokens carry no exp claim, so expires_at is checked directly and must not depend on the server time zone
+ALTER TABLE sessions
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN expires_at TYPE TIMESTAMPTZ;
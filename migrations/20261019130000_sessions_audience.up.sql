@@ -0,0 +1 @@
+ALTER TABLE sessions ADD COLUMN audience VARCHAR NOT NULL DEFAULT '';
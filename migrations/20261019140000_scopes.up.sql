@@ -0,0 +1,3 @@
+-- space-delimited scopes: granted to the user on top of the configured defaults, granted to the session at login
+ALTER TABLE users ADD COLUMN scopes VARCHAR NOT NULL DEFAULT '';
+ALTER TABLE sessions ADD COLUMN scope VARCHAR NOT NULL DEFAULT '';
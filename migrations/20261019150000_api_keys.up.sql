@@ -0,0 +1,14 @@
+CREATE TABLE api_keys
+(
+    id VARCHAR PRIMARY KEY NOT NULL,
+    user_id VARCHAR NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name VARCHAR NOT NULL,
+    prefix VARCHAR NOT NULL UNIQUE,
+    secret_hash VARCHAR NOT NULL,
+    scopes VARCHAR NOT NULL DEFAULT '',
+    expires_at TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX api_keys_user_id_idx ON api_keys (user_id);
@@ -0,0 +1,2 @@
+-- JWK thumbprint of the DPoP key the session's tokens are bound to, empty for bearer sessions
+ALTER TABLE sessions ADD COLUMN dpop_jkt VARCHAR NOT NULL DEFAULT '';
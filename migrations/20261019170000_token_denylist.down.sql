@@ -0,0 +1,3 @@
+ALTER TABLE users DROP COLUMN IF EXISTS is_disabled;
+ALTER TABLE sessions DROP COLUMN IF EXISTS access_token_id;
+DROP TABLE IF EXISTS token_denylist;
@@ -0,0 +1,11 @@
+-- access tokens revoked before their exp
+CREATE TABLE token_denylist
+(
+    jti VARCHAR PRIMARY KEY NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX token_denylist_expires_at_idx ON token_denylist (expires_at);
+
+ALTER TABLE sessions ADD COLUMN access_token_id VARCHAR NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN is_disabled BOOLEAN NOT NULL DEFAULT false;
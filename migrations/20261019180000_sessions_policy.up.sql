@@ -0,0 +1,6 @@
+ALTER TABLE sessions ADD COLUMN remember_me BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE sessions ADD COLUMN authenticated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE sessions ADD COLUMN last_used_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+-- the best we know for existing sessions
+UPDATE sessions SET authenticated_at = created_at, last_used_at = created_at;
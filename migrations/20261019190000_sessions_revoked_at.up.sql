@@ -0,0 +1,4 @@
+-- lets the janitor keep recently revoked sessions for a while before purging them
+ALTER TABLE sessions ADD COLUMN revoked_at TIMESTAMPTZ;
+
+CREATE INDEX sessions_expires_at_idx ON sessions (expires_at);
@@ -0,0 +1,9 @@
+ALTER TABLE sessions ADD COLUMN user_email VARCHAR;
+
+UPDATE sessions SET user_email = users.email FROM users WHERE users.id = sessions.user_id;
+
+DROP INDEX IF EXISTS sessions_user_id_idx;
+
+ALTER TABLE sessions
+    ALTER COLUMN user_email SET NOT NULL,
+    DROP COLUMN user_id;
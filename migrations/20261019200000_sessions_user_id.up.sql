@@ -0,0 +1,15 @@
+-- sessions were tied to users by email only; key them on users.id so that they
+-- follow email changes and go away with the user
+ALTER TABLE sessions ADD COLUMN user_id VARCHAR;
+
+UPDATE sessions SET user_id = users.id FROM users WHERE users.email = sessions.user_email;
+
+-- sessions of users that no longer exist cannot be used anyway
+DELETE FROM sessions WHERE user_id IS NULL;
+
+ALTER TABLE sessions
+    ALTER COLUMN user_id SET NOT NULL,
+    ADD CONSTRAINT sessions_user_id_fkey FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    DROP COLUMN user_email;
+
+CREATE INDEX sessions_user_id_idx ON sessions (user_id, created_at);
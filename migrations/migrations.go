@@ -0,0 +1,15 @@
+// Package migrations embeds the schema migrations, so that the binary can
+// apply them itself (auth migrate, auto_migrate) without the files on disk.
+package migrations
+
+import "embed"
+
+// FS holds the Postgres migrations.
+//
+//go:embed *.sql
+var FS embed.FS
+
+// SQLite holds the migrations of the SQLite backend, under sqlite/.
+//
+//go:embed sqlite/*.sql
+var SQLite embed.FS
@@ -0,0 +1,4 @@
+DROP TABLE token_denylist;
+DROP TABLE api_keys;
+DROP TABLE sessions;
+DROP TABLE users;
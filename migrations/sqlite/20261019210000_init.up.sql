@@ -0,0 +1,56 @@
+-- the SQLite schema starts at the state the Postgres migrations have reached;
+-- timestamps are written as UTC text, so that they compare in order
+CREATE TABLE users
+(
+    id TEXT NOT NULL PRIMARY KEY,
+    email TEXT NOT NULL UNIQUE,
+    encrypted_password TEXT NOT NULL,
+    pepper_version INTEGER NOT NULL DEFAULT 0,
+    scopes TEXT NOT NULL DEFAULT '',
+    is_disabled BOOLEAN NOT NULL DEFAULT false
+);
+
+CREATE TABLE sessions
+(
+    id TEXT NOT NULL PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    audience TEXT NOT NULL DEFAULT '',
+    scope TEXT NOT NULL DEFAULT '',
+    dpop_jkt TEXT NOT NULL DEFAULT '',
+    access_token_id TEXT NOT NULL DEFAULT '',
+    refresh_token_hash TEXT NOT NULL,
+    is_revoked BOOLEAN NOT NULL DEFAULT false,
+    revoked_at TIMESTAMP,
+    remember_me BOOLEAN NOT NULL DEFAULT false,
+    authenticated_at TIMESTAMP NOT NULL,
+    last_used_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX sessions_refresh_token_hash_idx ON sessions (refresh_token_hash);
+CREATE INDEX sessions_user_id_idx ON sessions (user_id, created_at);
+CREATE INDEX sessions_expires_at_idx ON sessions (expires_at);
+
+CREATE TABLE api_keys
+(
+    id TEXT NOT NULL PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL UNIQUE,
+    secret_hash TEXT NOT NULL,
+    scopes TEXT NOT NULL DEFAULT '',
+    expires_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX api_keys_user_id_idx ON api_keys (user_id);
+
+CREATE TABLE token_denylist
+(
+    jti TEXT NOT NULL PRIMARY KEY,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX token_denylist_expires_at_idx ON token_denylist (expires_at);